@@ -0,0 +1,138 @@
+// Package agentsigner signs with keys held by a local signing agent that
+// speaks the ssh-agent protocol over a Unix socket, such as ssh-agent itself,
+// so private key bytes never enter the application process.
+//
+// Agents sign whole messages rather than digests, so only ed25519 and ECDSA
+// P-256 keys are supported; agents sign RSA keys with PKCS #1 v1.5, not PSS.
+package agentsigner
+
+import (
+	"crypto"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+
+	"grpc-app-auth/signing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// SocketEnv names the environment variable NewFromEnv reads the agent
+// socket from.
+const SocketEnv = "SSH_AUTH_SOCK"
+
+var ErrNoKey = errors.New("signing agent holds no matching key")
+
+// Signer is a crypto.Signer for a key held by a signing agent. It dials the
+// agent for every signature, so it keeps working across agent restarts.
+type Signer struct {
+	socket    string
+	key       ssh.PublicKey
+	publicKey crypto.PublicKey
+}
+
+var _ signing.MessageSigner = (*Signer)(nil)
+
+// New returns a signer for the key matching publicKey held by the agent
+// listening on socket. A nil publicKey selects the first supported key.
+func New(socket string, publicKey crypto.PublicKey) (*Signer, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to signing agent: %w", err)
+	}
+	defer conn.Close()
+
+	keys, err := agent.NewClient(conn).List()
+	if err != nil {
+		return nil, fmt.Errorf("could not list agent keys: %w", err)
+	}
+
+	for _, key := range keys {
+		if key.Type() != ssh.KeyAlgoED25519 && key.Type() != ssh.KeyAlgoECDSA256 {
+			continue
+		}
+
+		parsed, err := ssh.ParsePublicKey(key.Marshal())
+		if err != nil {
+			continue
+		}
+		cryptoKey := parsed.(ssh.CryptoPublicKey).CryptoPublicKey()
+
+		if publicKey == nil || equal(cryptoKey, publicKey) {
+			return &Signer{socket: socket, key: parsed, publicKey: cryptoKey}, nil
+		}
+	}
+
+	return nil, ErrNoKey
+}
+
+// NewFromEnv is New with the socket named by $SSH_AUTH_SOCK.
+func NewFromEnv(publicKey crypto.PublicKey) (*Signer, error) {
+	socket := os.Getenv(SocketEnv)
+	if socket == "" {
+		return nil, fmt.Errorf("%s is not set", SocketEnv)
+	}
+	return New(socket, publicKey)
+}
+
+func (s *Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs ed25519 messages. Agents cannot sign precomputed digests, so
+// ECDSA keys must be used through SignMessage, as signing.Sign does.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != 0 {
+		return nil, fmt.Errorf("signing agent cannot sign a digest")
+	}
+	return s.SignMessage(rand, digest, opts)
+}
+
+// SignMessage asks the agent to sign message. ECDSA signatures are returned
+// ASN.1 DER encoded, as crypto/ecdsa produces them.
+func (s *Signer) SignMessage(rand io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	want := crypto.Hash(0)
+	if s.key.Type() == ssh.KeyAlgoECDSA256 {
+		want = crypto.SHA256
+	}
+	if opts.HashFunc() != want {
+		return nil, fmt.Errorf("%s keys cannot sign with hash %v", s.key.Type(), opts.HashFunc())
+	}
+
+	conn, err := net.Dial("unix", s.socket)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to signing agent: %w", err)
+	}
+	defer conn.Close()
+
+	signature, err := agent.NewClient(conn).Sign(s.key, message)
+	if err != nil {
+		return nil, fmt.Errorf("signing agent: %w", err)
+	}
+	if signature.Format != s.key.Type() {
+		return nil, fmt.Errorf("signing agent returned a %s signature", signature.Format)
+	}
+
+	if signature.Format == ssh.KeyAlgoED25519 {
+		return signature.Blob, nil
+	}
+
+	var ecdsaSignature struct {
+		R *big.Int
+		S *big.Int
+	}
+	if err := ssh.Unmarshal(signature.Blob, &ecdsaSignature); err != nil {
+		return nil, fmt.Errorf("malformed ECDSA signature from agent: %w", err)
+	}
+	return asn1.Marshal(ecdsaSignature)
+}
+
+func equal(a crypto.PublicKey, b crypto.PublicKey) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
@@ -0,0 +1,108 @@
+package agentsigner
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"grpc-app-auth/signing"
+
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// startAgent serves an in-memory keyring holding keys on a Unix socket.
+func startAgent(t *testing.T, keys ...crypto.PrivateKey) string {
+	t.Helper()
+
+	keyring := agent.NewKeyring()
+	for _, key := range keys {
+		if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	return socket
+}
+
+func TestAgentSignatures(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := startAgent(t, edKey, ecKey)
+
+	message := []byte("message")
+	for _, key := range []crypto.Signer{edKey, ecKey} {
+		signer, err := New(socket, key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		publicKey, _, err := signing.MarshalPublicKey(key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signature, alg, err := signing.Sign(signer, message)
+		if err != nil {
+			t.Fatalf("%T: %v", key, err)
+		}
+		if err := signing.Verify(alg, publicKey, message, signature); err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+	}
+}
+
+func TestNoMatchingKey(t *testing.T) {
+	_, heldKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := startAgent(t, heldKey)
+
+	if _, err := New(socket, otherPublicKey); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey, got %v", err)
+	}
+
+	signer, err := New(socket, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !heldKey.Public().(ed25519.PublicKey).Equal(signer.Public()) {
+		t.Fatal("expected the agent's only key to be selected")
+	}
+}
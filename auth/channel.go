@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// ChannelBindingLabel is the TLS exporter label (RFC 5705) of the value a
+	// channel bound signature covers.
+	ChannelBindingLabel = "EXPORTER-grpc-app-auth-channel-binding"
+
+	// ChannelBindingTLSExporter is the value of ChannelBindingMetadataKey for
+	// requests bound with the TLS exporter.
+	ChannelBindingTLSExporter = "tls-exporter"
+
+	channelBindingSize = 32
+)
+
+// channelBinding returns the exporter value of the TLS session described by
+// authInfo. Both ends of a session derive the same value, which a man in the
+// middle terminating TLS cannot reproduce on its own connection.
+func channelBinding(authInfo credentials.AuthInfo) ([]byte, error) {
+	tlsInfo, ok := authInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil, fmt.Errorf("connection does not use TLS")
+	}
+	return tlsInfo.State.ExportKeyingMaterial(ChannelBindingLabel, nil, channelBindingSize)
+}
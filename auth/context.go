@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Identity describes the key that authenticated a request. For requests signed
+// under a delegation, KeyID, Label and Groups describe the trusted key at the
+// root of the chain and Delegate is the ID of the key that signed. For
+// requests authorized by a quorum, they describe the first signer and Signers
+// lists every key counted toward the quorum.
+type Identity struct {
+	KeyID    string
+	Label    string
+	Groups   []string
+	Delegate string
+	Signers  []string
+}
+
+type identityContextKey struct{}
+
+// KeyIDFromContext returns the ID of the key that authenticated the request.
+func KeyIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := IdentityFromContext(ctx)
+	return id.KeyID, ok
+}
+
+// IdentityFromContext returns the identity that authenticated the request.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityContextKey{}).(Identity)
+	return id, ok
+}
+
+func contextWithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, id)
+}
+
+type requestContextKey struct{}
+
+func contextWithRequest(ctx context.Context, req proto.Message) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, req)
+}
+
+func requestFromContext(ctx context.Context) (proto.Message, bool) {
+	req, ok := ctx.Value(requestContextKey{}).(proto.Message)
+	return req, ok
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"errors"
+
+	"grpc-app-auth/keystore"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ErrorDomain is the domain of the errdetails.ErrorInfo attached to
+	// authentication failures.
+	ErrorDomain = "grpc-app-auth"
+
+	// Reasons attached to codes.Unauthenticated errors so callers can tell
+	// why a request was rejected.
+	ReasonStaleTimestamp   = "STALE_TIMESTAMP"
+	ReasonReplayedNonce    = "REPLAYED_NONCE"
+	ReasonUnknownKey       = "UNKNOWN_KEY"
+	ReasonRevokedKey       = "REVOKED_KEY"
+	ReasonExpiredKey       = "EXPIRED_KEY"
+	ReasonKeyNotYetValid   = "KEY_NOT_YET_VALID"
+	ReasonPendingKey       = "PENDING_KEY"
+	ReasonInvalidSignature = "INVALID_SIGNATURE"
+
+	ReasonDisallowedAlgorithm = "DISALLOWED_ALGORITHM"
+
+	ReasonInvalidSessionToken = "INVALID_SESSION_TOKEN"
+	ReasonExpiredSessionToken = "EXPIRED_SESSION_TOKEN"
+
+	ReasonInvalidDelegation = "INVALID_DELEGATION"
+	ReasonExpiredDelegation = "EXPIRED_DELEGATION"
+
+	ReasonInvalidMultiSignature = "INVALID_MULTISIGNATURE"
+	ReasonQuorumNotMet          = "QUORUM_NOT_MET"
+
+	ReasonInvalidChannelBinding = "INVALID_CHANNEL_BINDING"
+
+	// ReasonInvalidServerSignature is reported by a ResponseVerifier, not the
+	// server, when a response is unsigned or its signature does not match.
+	ReasonInvalidServerSignature = "INVALID_SERVER_SIGNATURE"
+)
+
+// authError returns a codes.Unauthenticated status carrying the given reason.
+func authError(reason string, message string) error {
+	st := status.New(codes.Unauthenticated, message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: ErrorDomain}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// KeyError converts an error from KeyStore.GetPublicKey into a
+// codes.Unauthenticated status with the matching reason.
+func KeyError(err error) error {
+	switch {
+	case errors.Is(err, keystore.ErrKeyNotFound):
+		return authError(ReasonUnknownKey, "public key is not trusted")
+	case errors.Is(err, keystore.ErrKeyRevoked):
+		return authError(ReasonRevokedKey, "public key has been revoked")
+	case errors.Is(err, keystore.ErrKeyExpired):
+		return authError(ReasonExpiredKey, "public key has expired")
+	case errors.Is(err, keystore.ErrKeyNotYetValid):
+		return authError(ReasonKeyNotYetValid, "public key is not yet valid")
+	case errors.Is(err, keystore.ErrKeyPending):
+		return authError(ReasonPendingKey, "public key is awaiting approval")
+	}
+	return status.Errorf(codes.Internal, "could not look up public key")
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Metadata keys carrying a request signature.
+const (
+	KeyMetadataKey       = "key"
+	SignatureMetadataKey = "signature"
+	TimestampMetadataKey = "timestamp"
+	NonceMetadataKey     = "nonce"
+
+	// AuthorizationMetadataKey carries a "Bearer <token>" session token in
+	// place of the signature metadata.
+	AuthorizationMetadataKey = "authorization"
+
+	// DelegationMetadataKey carries the delegation certificates, joined by
+	// delegation.JoinChain, that authorize the key in KeyMetadataKey.
+	DelegationMetadataKey = "delegation"
+
+	// ResponseNonceMetadataKey carries a fresh nonce from the client that the
+	// server binds its response signature to.
+	ResponseNonceMetadataKey = "response-nonce"
+
+	// Trailer keys carrying the server's response signature.
+	ServerKeyMetadataKey       = "server-key"
+	ServerSignatureMetadataKey = "server-signature"
+
+	// ChannelBindingMetadataKey marks a signature that also covers the TLS
+	// session it was sent on. Its value names the binding type.
+	ChannelBindingMetadataKey = "channel-binding"
+
+	bearerPrefix = "Bearer "
+	nonceSize    = 16
+)
+
+// NewNonce returns a random base64 encoded nonce.
+func NewNonce() (string, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(nonce), nil
+}
+
+func firstMetadataValue(md metadata.MD, key string) (string, bool) {
+	values := md.Get(key)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
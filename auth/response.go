@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+
+	"grpc-app-auth/canonical"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// ResponseSigner signs unary responses with the server's ed25519 identity key
+// so clients holding its public key can detect responses altered in transit.
+// Error statuses and streams are not signed.
+type ResponseSigner struct {
+	keyID      string
+	privateKey ed25519.PrivateKey
+}
+
+func NewResponseSigner(privateKey ed25519.PrivateKey) *ResponseSigner {
+	return &ResponseSigner{
+		keyID:      signing.Fingerprint(signing.Ed25519, privateKey.Public().(ed25519.PublicKey)),
+		privateKey: privateKey,
+	}
+}
+
+// KeyID returns the fingerprint of the server's identity key.
+func (s *ResponseSigner) KeyID() string {
+	return s.keyID
+}
+
+// UnaryServerInterceptor signs each successful response together with its
+// request and the caller's response nonce, sending the signature in trailers.
+func (s *ResponseSigner) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req interface{},
+		info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+
+		reqMsg, ok := req.(proto.Message)
+		if !ok {
+			return nil, status.Errorf(codes.Internal, "request is not a protobuf message")
+		}
+		respMsg, ok := resp.(proto.Message)
+		if !ok {
+			return nil, status.Errorf(codes.Internal, "response is not a protobuf message")
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		nonce, _ := firstMetadataValue(md, ResponseNonceMetadataKey)
+		payload, err := canonical.EncodeResponse(canonical.Response{
+			FullMethod: info.FullMethod,
+			Nonce:      nonce,
+			Request:    reqMsg,
+			Message:    respMsg,
+		})
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "could not encode response")
+		}
+
+		signature := ed25519.Sign(s.privateKey, payload)
+		if err := grpc.SetTrailer(ctx, metadata.Pairs(
+			ServerKeyMetadataKey, s.keyID,
+			ServerSignatureMetadataKey, base64.StdEncoding.EncodeToString(signature),
+		)); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// ResponseVerifier checks that unary responses are signed by a pinned server
+// key. Each call carries a fresh response nonce, so a response recorded from
+// an earlier call cannot be replayed. Calls whose response is unsigned or
+// does not match fail with ReasonInvalidServerSignature.
+type ResponseVerifier struct {
+	keyID     string
+	serverKey ed25519.PublicKey
+}
+
+func NewResponseVerifier(serverKey ed25519.PublicKey) *ResponseVerifier {
+	return &ResponseVerifier{
+		keyID:     signing.Fingerprint(signing.Ed25519, serverKey),
+		serverKey: serverKey,
+	}
+}
+
+// DialOptions installs the verifier on a connection.
+func (v *ResponseVerifier) DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{grpc.WithChainUnaryInterceptor(v.UnaryClientInterceptor())}
+}
+
+// UnaryClientInterceptor sends a response nonce with each call and verifies
+// the server's signature over the response once it arrives.
+func (v *ResponseVerifier) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context, method string, req, reply interface{},
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+	) error {
+		nonce, err := NewNonce()
+		if err != nil {
+			return err
+		}
+
+		var trailer metadata.MD
+		ctx = metadata.AppendToOutgoingContext(ctx, ResponseNonceMetadataKey, nonce)
+		if err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Trailer(&trailer))...); err != nil {
+			return err
+		}
+		return v.verify(method, nonce, req, reply, trailer)
+	}
+}
+
+func (v *ResponseVerifier) verify(fullMethod string, nonce string, req, reply interface{}, trailer metadata.MD) error {
+	keyID, ok := firstMetadataValue(trailer, ServerKeyMetadataKey)
+	if !ok {
+		return authError(ReasonInvalidServerSignature, "response is not signed")
+	}
+	if keyID != v.keyID {
+		return authError(ReasonInvalidServerSignature, "response was signed by an unexpected key")
+	}
+
+	encoded, _ := firstMetadataValue(trailer, ServerSignatureMetadataKey)
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return authError(ReasonInvalidServerSignature, "malformed response signature")
+	}
+
+	reqMsg, ok := req.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "request is not a protobuf message")
+	}
+	replyMsg, ok := reply.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "response is not a protobuf message")
+	}
+
+	payload, err := canonical.EncodeResponse(canonical.Response{
+		FullMethod: fullMethod,
+		Nonce:      nonce,
+		Request:    reqMsg,
+		Message:    replyMsg,
+	})
+	if err != nil {
+		return status.Errorf(codes.Internal, "could not encode response")
+	}
+	if !ed25519.Verify(v.serverKey, payload, signature) {
+		return authError(ReasonInvalidServerSignature, "response signature is not valid")
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+
+	"grpc-app-auth/canonical"
+	"grpc-app-auth/delegation"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// Signer signs every outgoing request with a private key. It implements
+// credentials.PerRPCCredentials so it works with any generated client stub.
+type Signer struct {
+	keyID      string
+	privateKey crypto.Signer
+	// delegation is the joined certificate chain authorizing privateKey, if any.
+	delegation string
+	// channelBinding binds signatures to the TLS session they are sent on.
+	channelBinding bool
+}
+
+var _ credentials.PerRPCCredentials = (*Signer)(nil)
+
+// NewSigner signs requests with privateKey, which may be an
+// ed25519.PrivateKey, a P-256 *ecdsa.PrivateKey, an *rsa.PrivateKey or any
+// other crypto.Signer for one of those keys, such as a hardware token.
+func NewSigner(keyID string, privateKey crypto.Signer) *Signer {
+	return &Signer{keyID: keyID, privateKey: privateKey}
+}
+
+// NewDelegatedSigner signs requests with privateKey under the given chain of
+// delegation certificates, the one issued by a trusted key first. The last
+// certificate's subject must be privateKey's public key.
+func NewDelegatedSigner(privateKey crypto.Signer, chain ...string) (*Signer, error) {
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("at least one delegation certificate is required")
+	}
+
+	keyID, err := signing.PublicKeyFingerprint(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	joined := delegation.JoinChain(chain...)
+	parsed, err := delegation.ParseChain(joined)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Leaf().SubjectKeyID() != keyID {
+		return nil, fmt.Errorf("last delegation certificate is not for key %s", keyID)
+	}
+
+	return &Signer{keyID: keyID, privateKey: privateKey, delegation: joined}, nil
+}
+
+// WithChannelBinding returns a copy of the signer whose signatures also cover
+// the TLS session they are sent on, so they are rejected if relayed over
+// another connection. The connection must use TLS 1.3 or extended master
+// secret; see the tlsutil package.
+func (s *Signer) WithChannelBinding() *Signer {
+	bound := *s
+	bound.channelBinding = true
+	return &bound
+}
+
+// DialOptions installs the signer on a connection. Unary requests are bound
+// into the signature by an interceptor, so both options are required.
+func (s *Signer) DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithPerRPCCredentials(s),
+		grpc.WithChainUnaryInterceptor(s.UnaryClientInterceptor()),
+	}
+}
+
+// UnaryClientInterceptor makes the request message available to
+// GetRequestMetadata so that it is covered by the signature.
+func (s *Signer) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return RequestUnaryClientInterceptor()
+}
+
+// RequestUnaryClientInterceptor makes the request message available to any
+// Signer used for the call. Connections that pick their credentials per call
+// install it once instead of a particular signer's interceptor.
+func RequestUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context, method string, req, reply interface{},
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+	) error {
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return fmt.Errorf("request is not a protobuf message")
+		}
+		return invoker(contextWithRequest(ctx, msg), method, req, reply, cc, opts...)
+	}
+}
+
+// GetRequestMetadata signs the method being called with a fresh timestamp and
+// nonce. grpc calls it for every attempt, so retries are never replays.
+func (s *Signer) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	ri, ok := credentials.RequestInfoFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("missing request info")
+	}
+
+	nonce, err := NewNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	var binding []byte
+	if s.channelBinding {
+		if binding, err = channelBinding(ri.AuthInfo); err != nil {
+			return nil, fmt.Errorf("could not bind signature to channel: %w", err)
+		}
+	}
+
+	req, _ := requestFromContext(ctx)
+	headers, err := signatureHeaders(s.privateKey, s.keyID, ri.Method, req, time.Now(), nonce, binding)
+	if err != nil {
+		return nil, err
+	}
+	if binding != nil {
+		headers[ChannelBindingMetadataKey] = ChannelBindingTLSExporter
+	}
+	if s.delegation != "" {
+		headers[DelegationMetadataKey] = s.delegation
+	}
+	return headers, nil
+}
+
+// RequireTransportSecurity reports false because the signature protects the
+// request without relying on the transport.
+func (s *Signer) RequireTransportSecurity() bool {
+	return false
+}
+
+// Sign returns the metadata authenticating req as a call to fullMethod.
+func Sign(privateKey crypto.Signer, keyID string, fullMethod string, req proto.Message, timestamp time.Time, nonce string) (metadata.MD, error) {
+	headers, err := signatureHeaders(privateKey, keyID, fullMethod, req, timestamp, nonce, nil)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.New(headers), nil
+}
+
+// AppendSignature signs req with the current time and a fresh nonce and
+// appends the resulting metadata to the outgoing context.
+func AppendSignature(ctx context.Context, privateKey crypto.Signer, keyID string, fullMethod string, req proto.Message) (context.Context, error) {
+	nonce, err := NewNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	md, err := Sign(privateKey, keyID, fullMethod, req, time.Now(), nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewOutgoingContext(ctx, metadata.Join(existing, md)), nil
+}
+
+func signatureHeaders(privateKey crypto.Signer, keyID string, fullMethod string, req proto.Message, timestamp time.Time, nonce string, binding []byte) (map[string]string, error) {
+	payload, err := canonical.EncodeRequest(canonical.Request{
+		FullMethod:     fullMethod,
+		Timestamp:      timestamp.Unix(),
+		Nonce:          nonce,
+		Message:        req,
+		ChannelBinding: binding,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	signature, _, err := signing.Sign(privateKey, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		KeyMetadataKey:       keyID,
+		SignatureMetadataKey: base64.StdEncoding.EncodeToString(signature),
+		TimestampMetadataKey: strconv.FormatInt(timestamp.Unix(), 10),
+		NonceMetadataKey:     nonce,
+	}, nil
+}
@@ -0,0 +1,632 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc-app-auth/canonical"
+	"grpc-app-auth/delegation"
+	"grpc-app-auth/internal/replay"
+	"grpc-app-auth/internal/stringutils"
+	"grpc-app-auth/keystore"
+	"grpc-app-auth/multisig"
+	"grpc-app-auth/session"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	DefaultReplayWindow   = time.Minute
+	DefaultNonceCacheSize = 100000
+
+	// DefaultMultiSignatureWindow is how long a multi-signature envelope is
+	// accepted after it was created, leaving time to collect co-signatures.
+	DefaultMultiSignatureWindow = 15 * time.Minute
+)
+
+// Verifier authenticates requests signed by keys in a KeyStore.
+type Verifier struct {
+	trustedKeys keystore.KeyStore
+	serviceKeys map[string]keystore.KeyStore
+	public      map[string]bool
+	sessionKey  ed25519.PublicKey
+	authorizer  Authorizer
+	algorithms  map[signing.Algorithm]bool
+	replayGuard *replay.Guard
+	quorums     map[string]quorum
+	// requireChannelBinding rejects unbound signatures on TLS connections.
+	requireChannelBinding bool
+	// multiSigGuard remembers envelope nonces for the multi-signature window.
+	multiSigGuard *replay.Guard
+}
+
+// quorum is the number of distinct keys from group that must sign a call.
+type quorum struct {
+	threshold int
+	group     string
+}
+
+// Authorizer decides whether an authenticated identity may call a method.
+// Errors that are not status errors are reported as codes.PermissionDenied.
+type Authorizer interface {
+	Authorize(ctx context.Context, id Identity, fullMethod string) error
+}
+
+type VerifierOption func(*verifierOptions) error
+
+type verifierOptions struct {
+	replayWindow   time.Duration
+	nonceCacheSize int
+	serviceKeys    map[string]keystore.KeyStore
+	public         map[string]bool
+	sessionKey     ed25519.PublicKey
+	authorizer     Authorizer
+	algorithms     []signing.Algorithm
+	quorums        map[string]quorum
+	multiSigWindow time.Duration
+	channelBinding bool
+}
+
+// WithReplayWindow sets how far a request timestamp may drift from the server
+// clock before the request is rejected.
+func WithReplayWindow(window time.Duration) VerifierOption {
+	return func(o *verifierOptions) error {
+		if window <= 0 {
+			return fmt.Errorf("replay window must be positive")
+		}
+		o.replayWindow = window
+		return nil
+	}
+}
+
+// WithNonceCacheSize bounds the number of nonces remembered for replay protection.
+func WithNonceCacheSize(size int) VerifierOption {
+	return func(o *verifierOptions) error {
+		if size <= 0 {
+			return fmt.Errorf("nonce cache size must be positive")
+		}
+		o.nonceCacheSize = size
+		return nil
+	}
+}
+
+// WithServiceKeyStore verifies calls to the named service, e.g.
+// "services.KeyAdmin", against keys instead of the default KeyStore.
+func WithServiceKeyStore(service string, keys keystore.KeyStore) VerifierOption {
+	return func(o *verifierOptions) error {
+		if keys == nil {
+			return fmt.Errorf("key store for %s must not be nil", service)
+		}
+		o.serviceKeys[service] = keys
+		return nil
+	}
+}
+
+// WithUnauthenticatedService lets calls to the named service through without
+// a signature. It is meant for services such as enrollment that authenticate
+// callers themselves.
+func WithUnauthenticatedService(service string) VerifierOption {
+	return func(o *verifierOptions) error {
+		o.public[service] = true
+		return nil
+	}
+}
+
+// WithSessionKey accepts session tokens signed by publicKey in place of a
+// request signature. Tokens are not accepted for services configured with
+// WithServiceKeyStore.
+func WithSessionKey(publicKey ed25519.PublicKey) VerifierOption {
+	return func(o *verifierOptions) error {
+		if len(publicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("session key must be %d bytes", ed25519.PublicKeySize)
+		}
+		o.sessionKey = publicKey
+		return nil
+	}
+}
+
+// WithAuthorizer checks every authenticated call against authorizer.
+// Services registered with WithUnauthenticatedService are not checked.
+func WithAuthorizer(authorizer Authorizer) VerifierOption {
+	return func(o *verifierOptions) error {
+		o.authorizer = authorizer
+		return nil
+	}
+}
+
+// WithAllowedAlgorithms restricts the signature algorithms keys may use.
+// Requests signed by a key whose record names any other algorithm are
+// rejected. All supported algorithms are allowed by default.
+func WithAllowedAlgorithms(algorithms ...signing.Algorithm) VerifierOption {
+	return func(o *verifierOptions) error {
+		if len(algorithms) == 0 {
+			return fmt.Errorf("at least one algorithm must be allowed")
+		}
+		o.algorithms = nil
+		for _, name := range algorithms {
+			alg, err := signing.ParseAlgorithm(string(name))
+			if err != nil {
+				return err
+			}
+			o.algorithms = append(o.algorithms, alg)
+		}
+		return nil
+	}
+}
+
+// WithQuorum requires calls to fullMethod to carry a multisig.Envelope signed
+// by at least threshold distinct keys in group; single signatures and session
+// tokens are refused. A method ending in '*' covers every method with that
+// prefix, and an empty group counts any trusted key.
+func WithQuorum(fullMethod string, threshold int, group string) VerifierOption {
+	return func(o *verifierOptions) error {
+		if threshold < 1 {
+			return fmt.Errorf("quorum threshold must be at least 1")
+		}
+		o.quorums[fullMethod] = quorum{threshold: threshold, group: group}
+		return nil
+	}
+}
+
+// WithMultiSignatureWindow sets how long a multi-signature envelope is
+// accepted after it was created.
+func WithMultiSignatureWindow(window time.Duration) VerifierOption {
+	return func(o *verifierOptions) error {
+		if window <= 0 {
+			return fmt.Errorf("multi-signature window must be positive")
+		}
+		o.multiSigWindow = window
+		return nil
+	}
+}
+
+// WithChannelBinding requires signed requests received over TLS to be bound to
+// their TLS session (see Signer.WithChannelBinding). Bound requests are
+// verified against the session either way; this rejects unbound ones. Quorum
+// methods cannot be bound, so calls to them over TLS are rejected too.
+func WithChannelBinding() VerifierOption {
+	return func(o *verifierOptions) error {
+		o.channelBinding = true
+		return nil
+	}
+}
+
+func NewVerifier(trustedKeys keystore.KeyStore, opts ...VerifierOption) (*Verifier, error) {
+	// apply defaults
+	o := &verifierOptions{
+		replayWindow:   DefaultReplayWindow,
+		nonceCacheSize: DefaultNonceCacheSize,
+		serviceKeys:    make(map[string]keystore.KeyStore),
+		public:         make(map[string]bool),
+		algorithms:     signing.Algorithms(),
+		quorums:        make(map[string]quorum),
+		multiSigWindow: DefaultMultiSignatureWindow,
+	}
+
+	// apply user options
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, fmt.Errorf("error applying option: %w", err)
+		}
+	}
+
+	algorithms := make(map[signing.Algorithm]bool)
+	for _, alg := range o.algorithms {
+		algorithms[alg] = true
+	}
+
+	return &Verifier{
+		trustedKeys:           trustedKeys,
+		serviceKeys:           o.serviceKeys,
+		public:                o.public,
+		sessionKey:            o.sessionKey,
+		authorizer:            o.authorizer,
+		algorithms:            algorithms,
+		replayGuard:           replay.NewGuard(o.replayWindow, o.nonceCacheSize),
+		quorums:               o.quorums,
+		requireChannelBinding: o.channelBinding,
+		multiSigGuard:         replay.NewGuard(o.multiSigWindow, o.nonceCacheSize),
+	}, nil
+}
+
+// UnaryServerInterceptor verifies the signature over the method name and
+// request before calling the handler.
+func (v *Verifier) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req interface{},
+		info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return nil, status.Errorf(codes.Internal, "request is not a protobuf message")
+		}
+
+		ctx, err := v.verify(ctx, info.FullMethod, msg)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor verifies the signature over the method name when a
+// stream is opened.
+func (v *Verifier) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{}, ss grpc.ServerStream,
+		info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+	) error {
+		ctx, err := v.verify(ss.Context(), info.FullMethod, nil)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (v *Verifier) verify(ctx context.Context, fullMethod string, req proto.Message) (context.Context, error) {
+	if service, _, err := canonical.SplitMethod(fullMethod); err == nil && v.public[service] {
+		return ctx, nil
+	}
+
+	id, err := v.authenticate(ctx, fullMethod, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.authorizer != nil {
+		if err := v.authorizer.Authorize(ctx, id, fullMethod); err != nil {
+			if _, ok := status.FromError(err); ok {
+				return nil, err
+			}
+			return nil, status.Errorf(codes.PermissionDenied, "%v", err)
+		}
+	}
+
+	return contextWithIdentity(ctx, id), nil
+}
+
+func (v *Verifier) authenticate(ctx context.Context, fullMethod string, req proto.Message) (Identity, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return Identity{}, status.Errorf(codes.Unauthenticated, "missing authentication metadata")
+	}
+
+	if q, ok := v.quorumFor(fullMethod); ok {
+		return v.verifyQuorum(ctx, md, fullMethod, req, q)
+	}
+
+	if authorization, ok := firstMetadataValue(md, AuthorizationMetadataKey); ok {
+		return v.verifySession(fullMethod, authorization)
+	}
+
+	keyID, ok := firstMetadataValue(md, KeyMetadataKey)
+	if !ok {
+		return Identity{}, status.Errorf(codes.Unauthenticated, "missing key")
+	}
+
+	signature, ok := firstMetadataValue(md, SignatureMetadataKey)
+	if !ok {
+		return Identity{}, status.Errorf(codes.Unauthenticated, "missing signature")
+	}
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return Identity{}, status.Errorf(codes.Unauthenticated, "malformed signature")
+	}
+
+	timestampStr, ok := firstMetadataValue(md, TimestampMetadataKey)
+	if !ok {
+		return Identity{}, status.Errorf(codes.Unauthenticated, "missing timestamp")
+	}
+
+	timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
+	if err != nil {
+		return Identity{}, status.Errorf(codes.Unauthenticated, "malformed timestamp")
+	}
+
+	nonce, ok := firstMetadataValue(md, NonceMetadataKey)
+	if !ok || nonce == "" {
+		return Identity{}, status.Errorf(codes.Unauthenticated, "missing nonce")
+	}
+
+	// Under a delegation the trusted key is the root of the chain and the
+	// request is signed by the subject of its last certificate.
+	rootKeyID := keyID
+	var chain delegation.Chain
+	if certificates, ok := firstMetadataValue(md, DelegationMetadataKey); ok {
+		if chain, err = delegation.ParseChain(certificates); err != nil {
+			return Identity{}, authError(ReasonInvalidDelegation, "delegation certificate is malformed")
+		}
+		rootKeyID = chain.Root()
+	}
+
+	record, err := v.keyStoreFor(fullMethod).GetKeyRecord(rootKeyID)
+	if err == nil {
+		err = record.Validate(time.Now())
+	}
+	if err != nil {
+		return Identity{}, KeyError(err)
+	}
+
+	if !v.AllowsAlgorithm(record.Algorithm) {
+		return Identity{}, authError(ReasonDisallowedAlgorithm, "key algorithm is not allowed")
+	}
+
+	alg, publicKey := record.Algorithm, record.PublicKey
+	if chain != nil {
+		if err := v.verifyDelegation(chain, record, keyID, fullMethod); err != nil {
+			return Identity{}, err
+		}
+		alg, publicKey = chain.Leaf().SubjectAlgorithm, chain.Leaf().SubjectKey
+	}
+
+	binding, err := v.channelBinding(ctx, md)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	payload, err := canonical.EncodeRequest(canonical.Request{
+		FullMethod:     fullMethod,
+		Timestamp:      timestamp,
+		Nonce:          nonce,
+		Message:        req,
+		ChannelBinding: binding,
+	})
+	if err != nil {
+		return Identity{}, status.Errorf(codes.Internal, "could not encode request")
+	}
+
+	if err := signing.Verify(alg, publicKey, payload, signatureBytes); err != nil {
+		return Identity{}, authError(ReasonInvalidSignature, "signature is not valid")
+	}
+
+	// The replay check runs after the signature check so that forged requests
+	// cannot burn the nonces of genuine ones.
+	if err := checkReplay(v.replayGuard, time.Unix(timestamp, 0), keyID+"|"+nonce); err != nil {
+		return Identity{}, err
+	}
+
+	id := Identity{KeyID: rootKeyID, Label: record.Label, Groups: record.Groups}
+	if chain != nil {
+		id.Delegate = keyID
+	}
+	return id, nil
+}
+
+// channelBinding returns the binding a request's signature must cover: the
+// exporter value of the TLS session it arrived on if the client bound it, or
+// nil. Dropping the marker does not help an attacker, since the signature
+// then no longer matches.
+func receivedOverTLS(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	_, isTLS := p.AuthInfo.(credentials.TLSInfo)
+	return isTLS
+}
+
+func (v *Verifier) channelBinding(ctx context.Context, md metadata.MD) ([]byte, error) {
+	var authInfo credentials.AuthInfo
+	if p, ok := peer.FromContext(ctx); ok {
+		authInfo = p.AuthInfo
+	}
+
+	bindingType, bound := firstMetadataValue(md, ChannelBindingMetadataKey)
+	if !bound {
+		if _, isTLS := authInfo.(credentials.TLSInfo); isTLS && v.requireChannelBinding {
+			return nil, authError(ReasonInvalidChannelBinding, "request is not bound to the TLS session")
+		}
+		return nil, nil
+	}
+	if bindingType != ChannelBindingTLSExporter {
+		return nil, authError(ReasonInvalidChannelBinding, "unsupported channel binding")
+	}
+
+	binding, err := channelBinding(authInfo)
+	if err != nil {
+		return nil, authError(ReasonInvalidChannelBinding, "channel binding is not available on this connection")
+	}
+	return binding, nil
+}
+
+// verifyDelegation checks that chain leads from the trusted key root to the
+// key keyID that signed the request, and that it grants fullMethod.
+func (v *Verifier) verifyDelegation(chain delegation.Chain, root keystore.KeyRecord, keyID string, fullMethod string) error {
+	if chain.Leaf().SubjectKeyID() != keyID {
+		return authError(ReasonInvalidDelegation, "delegation is not for the signing key")
+	}
+
+	for _, c := range chain {
+		if !v.AllowsAlgorithm(c.SubjectAlgorithm) {
+			return authError(ReasonDisallowedAlgorithm, "delegated key algorithm is not allowed")
+		}
+	}
+
+	err := chain.Verify(root.Algorithm, root.PublicKey, time.Now())
+	if errors.Is(err, delegation.ErrExpiredCertificate) {
+		return authError(ReasonExpiredDelegation, "delegation certificate has expired")
+	} else if err != nil {
+		return authError(ReasonInvalidDelegation, "delegation certificate is not valid")
+	}
+
+	if !chain.Allows(fullMethod) {
+		return status.Errorf(codes.PermissionDenied, "delegation does not grant %s", fullMethod)
+	}
+	return nil
+}
+
+// verifySession authenticates a request carrying a session token. Tokens are
+// self-contained, so no KeyStore lookup is needed.
+func (v *Verifier) verifySession(fullMethod string, authorization string) (Identity, error) {
+	if v.sessionKey == nil {
+		return Identity{}, authError(ReasonInvalidSessionToken, "session tokens are not accepted")
+	}
+
+	if service, _, err := canonical.SplitMethod(fullMethod); err == nil {
+		if _, ok := v.serviceKeys[service]; ok {
+			return Identity{}, authError(ReasonInvalidSessionToken, "session tokens are not accepted for this service")
+		}
+	}
+
+	token, ok := strings.CutPrefix(authorization, bearerPrefix)
+	if !ok {
+		return Identity{}, authError(ReasonInvalidSessionToken, "authorization must be a bearer token")
+	}
+
+	claims, err := session.Verify(v.sessionKey, token, time.Now())
+	if errors.Is(err, session.ErrExpiredToken) {
+		return Identity{}, authError(ReasonExpiredSessionToken, "session token has expired")
+	} else if err != nil {
+		return Identity{}, authError(ReasonInvalidSessionToken, "session token is not valid")
+	}
+
+	if !claims.Allows(fullMethod) {
+		return Identity{}, status.Errorf(codes.PermissionDenied, "session token does not grant %s", fullMethod)
+	}
+
+	return Identity{KeyID: claims.KeyID, Label: claims.Label, Groups: claims.Groups}, nil
+}
+
+// verifyQuorum authenticates a request carrying a multisig.Envelope. Every
+// signature in the envelope must be valid and come from a usable key, and at
+// least q.threshold distinct signers must belong to q.group.
+func (v *Verifier) verifyQuorum(ctx context.Context, md metadata.MD, fullMethod string, req proto.Message, q quorum) (Identity, error) {
+	// Co-signatures are collected before the connection they are sent on
+	// exists, so they can never be bound to it.
+	if v.requireChannelBinding && receivedOverTLS(ctx) {
+		return Identity{}, authError(ReasonInvalidChannelBinding, "multi-signature envelopes cannot be bound to the TLS session")
+	}
+
+	encoded, ok := firstMetadataValue(md, multisig.MetadataKey)
+	if !ok {
+		return Identity{}, authError(ReasonQuorumNotMet, fmt.Sprintf("%s requires %d signatures", fullMethod, q.threshold))
+	}
+
+	envelope, err := multisig.Parse(encoded)
+	if err != nil || envelope.FullMethod != fullMethod {
+		return Identity{}, authError(ReasonInvalidMultiSignature, "multi-signature envelope is malformed")
+	}
+
+	body, err := canonical.Marshal(req)
+	if err != nil {
+		return Identity{}, status.Errorf(codes.Internal, "could not encode request")
+	}
+	payload, err := canonical.EncodeMultisig(fullMethod, envelope.Timestamp, envelope.Nonce, body)
+	if err != nil {
+		return Identity{}, status.Errorf(codes.Internal, "could not encode request")
+	}
+
+	keys := v.keyStoreFor(fullMethod)
+	seen := make(map[string]bool)
+	var id Identity
+	for _, s := range envelope.Signatures {
+		if seen[s.KeyID] {
+			continue
+		}
+		seen[s.KeyID] = true
+
+		record, err := keys.GetKeyRecord(s.KeyID)
+		if err == nil {
+			err = record.Validate(time.Now())
+		}
+		if err != nil {
+			return Identity{}, KeyError(err)
+		}
+		if !v.AllowsAlgorithm(record.Algorithm) {
+			return Identity{}, authError(ReasonDisallowedAlgorithm, "key algorithm is not allowed")
+		}
+		if err := signing.Verify(record.Algorithm, record.PublicKey, payload, s.Signature); err != nil {
+			return Identity{}, authError(ReasonInvalidSignature, "signature is not valid")
+		}
+
+		if q.group != "" && !stringutils.Contains(record.Groups, q.group) {
+			continue
+		}
+		if id.Signers == nil {
+			id = Identity{KeyID: record.ID, Label: record.Label, Groups: record.Groups}
+		}
+		id.Signers = append(id.Signers, record.ID)
+	}
+
+	if len(id.Signers) < q.threshold {
+		return Identity{}, authError(ReasonQuorumNotMet, fmt.Sprintf("%d of %d required signatures", len(id.Signers), q.threshold))
+	}
+
+	// Envelopes are not tied to one key, so their nonces share one scope.
+	if err := checkReplay(v.multiSigGuard, time.Unix(envelope.Timestamp, 0), envelope.Nonce); err != nil {
+		return Identity{}, err
+	}
+
+	return id, nil
+}
+
+// quorumFor returns the quorum configured for fullMethod, preferring the
+// longest matching pattern.
+func (v *Verifier) quorumFor(fullMethod string) (quorum, bool) {
+	var match string
+	found := false
+	for pattern := range v.quorums {
+		if canonical.MatchMethod(pattern, fullMethod) && (!found || len(pattern) > len(match)) {
+			match, found = pattern, true
+		}
+	}
+	return v.quorums[match], found
+}
+
+// AllowsAlgorithm reports whether keys using alg are accepted. The empty
+// algorithm is signing.Ed25519.
+func (v *Verifier) AllowsAlgorithm(alg signing.Algorithm) bool {
+	alg, err := signing.ParseAlgorithm(string(alg))
+	return err == nil && v.algorithms[alg]
+}
+
+func (v *Verifier) keyStoreFor(fullMethod string) keystore.KeyStore {
+	if service, _, err := canonical.SplitMethod(fullMethod); err == nil {
+		if keys, ok := v.serviceKeys[service]; ok {
+			return keys
+		}
+	}
+	return v.trustedKeys
+}
+
+// checkReplay records the nonce with guard. Request nonces are scoped per key
+// so one client cannot burn another's nonces.
+func checkReplay(guard *replay.Guard, timestamp time.Time, nonce string) error {
+	err := guard.Check(timestamp, nonce)
+	switch {
+	case errors.Is(err, replay.ErrStaleTimestamp):
+		return authError(ReasonStaleTimestamp, "request timestamp is outside the allowed window")
+	case errors.Is(err, replay.ErrReplayedNonce):
+		return authError(ReasonReplayedNonce, "request has already been seen")
+	case errors.Is(err, replay.ErrCacheFull):
+		return status.Errorf(codes.ResourceExhausted, "too many requests in flight")
+	case err != nil:
+		return status.Errorf(codes.Internal, "replay check failed")
+	}
+	return nil
+}
+
+// authenticatedStream exposes the authenticated context to stream handlers.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
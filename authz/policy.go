@@ -0,0 +1,155 @@
+// Package authz decides which authenticated keys may call which methods.
+//
+// A policy is a JSON document such as:
+//
+//	{
+//	  "default": "deny",
+//	  "rules": [
+//	    {"groups": ["admins"], "methods": ["/services.KeyAdmin/*"]},
+//	    {"labels": ["build agent"], "methods": ["/services.Add/Add"]},
+//	    {"keys": ["ed25519:u1NXxqP..."], "methods": ["*"]}
+//	  ]
+//	}
+//
+// A rule matches a caller if its key ID, label or any of its groups is listed,
+// and grants the listed methods. A method ending in '*' matches every method
+// with that prefix. In "deny" mode, the default, a call is allowed only if a
+// rule grants it. In "allow" mode, methods no rule mentions are open to every
+// authenticated key, and methods a rule mentions are restricted to the
+// callers that rule matches.
+package authz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"grpc-app-auth/auth"
+	"grpc-app-auth/canonical"
+	"grpc-app-auth/internal/stringutils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DefaultDeny  = "deny"
+	DefaultAllow = "allow"
+)
+
+type Policy struct {
+	Default string `json:"default"`
+	Rules   []Rule `json:"rules"`
+}
+
+type Rule struct {
+	Keys    []string `json:"keys,omitempty"`
+	Labels  []string `json:"labels,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+	Methods []string `json:"methods"`
+}
+
+var _ auth.Authorizer = (*Policy)(nil)
+
+// LoadPolicyFile reads and validates a policy from a JSON file.
+func LoadPolicyFile(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := ParsePolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// ParsePolicy parses and validates a JSON policy. Unknown fields are
+// rejected so that typos cannot silently widen access.
+func ParsePolicy(data []byte) (*Policy, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var policy Policy
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, err
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Validate checks the policy for mistakes.
+func (p *Policy) Validate() error {
+	switch p.Default {
+	case "", DefaultDeny, DefaultAllow:
+	default:
+		return fmt.Errorf("default must be %q or %q", DefaultDeny, DefaultAllow)
+	}
+
+	for i, rule := range p.Rules {
+		if len(rule.Keys)+len(rule.Labels)+len(rule.Groups) == 0 {
+			return fmt.Errorf("rule %d: must list keys, labels or groups", i)
+		}
+		if len(rule.Methods) == 0 {
+			return fmt.Errorf("rule %d: must list methods", i)
+		}
+		for _, method := range rule.Methods {
+			if method != "*" && !strings.HasPrefix(method, "/") {
+				return fmt.Errorf("rule %d: method %q must be a full method name", i, method)
+			}
+		}
+	}
+	return nil
+}
+
+// Allows reports whether id may call fullMethod.
+func (p *Policy) Allows(id auth.Identity, fullMethod string) bool {
+	mentioned := false
+	for _, rule := range p.Rules {
+		if !rule.coversMethod(fullMethod) {
+			continue
+		}
+		mentioned = true
+		if rule.matches(id) {
+			return true
+		}
+	}
+
+	return p.Default == DefaultAllow && !mentioned
+}
+
+// Authorize implements auth.Authorizer.
+func (p *Policy) Authorize(ctx context.Context, id auth.Identity, fullMethod string) error {
+	if !p.Allows(id, fullMethod) {
+		return status.Errorf(codes.PermissionDenied, "key %s may not call %s", id.KeyID, fullMethod)
+	}
+	return nil
+}
+
+func (r *Rule) coversMethod(fullMethod string) bool {
+	for _, method := range r.Methods {
+		if canonical.MatchMethod(method, fullMethod) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matches(id auth.Identity) bool {
+	if stringutils.Contains(r.Keys, id.KeyID) || (id.Label != "" && stringutils.Contains(r.Labels, id.Label)) {
+		return true
+	}
+	for _, group := range id.Groups {
+		if stringutils.Contains(r.Groups, group) {
+			return true
+		}
+	}
+	return false
+}
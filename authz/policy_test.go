@@ -0,0 +1,74 @@
+package authz
+
+import (
+	"testing"
+
+	"grpc-app-auth/auth"
+)
+
+func TestDenyByDefault(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`{
+		"rules": [
+			{"groups": ["admins"], "methods": ["/services.KeyAdmin/*"]},
+			{"labels": ["calculator"], "methods": ["/services.Add/Add"]},
+			{"keys": ["root"], "methods": ["*"]}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := auth.Identity{KeyID: "a", Groups: []string{"ops", "admins"}}
+	calculator := auth.Identity{KeyID: "c", Label: "calculator"}
+	root := auth.Identity{KeyID: "root"}
+	nobody := auth.Identity{KeyID: "n"}
+
+	for _, tc := range []struct {
+		id     auth.Identity
+		method string
+		want   bool
+	}{
+		{admin, "/services.KeyAdmin/RevokeKey", true},
+		{admin, "/services.Add/Add", false},
+		{calculator, "/services.Add/Add", true},
+		{calculator, "/services.Echo/Echo", false},
+		{root, "/services.Echo/Echo", true},
+		{nobody, "/services.Echo/Echo", false},
+	} {
+		if got := policy.Allows(tc.id, tc.method); got != tc.want {
+			t.Errorf("%s calling %s: got %v, want %v", tc.id.KeyID, tc.method, got, tc.want)
+		}
+	}
+}
+
+func TestAllowByDefault(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`{
+		"default": "allow",
+		"rules": [{"groups": ["admins"], "methods": ["/services.KeyAdmin/*"]}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := auth.Identity{KeyID: "c"}
+	if !policy.Allows(client, "/services.Echo/Echo") {
+		t.Error("expected unmentioned method to be allowed")
+	}
+	if policy.Allows(client, "/services.KeyAdmin/ListKeys") {
+		t.Error("expected restricted method to be denied")
+	}
+}
+
+func TestParsePolicyRejectsMistakes(t *testing.T) {
+	for _, bad := range []string{
+		`{"default": "maybe"}`,
+		`{"rules": [{"methods": ["*"]}]}`,
+		`{"rules": [{"keys": ["k"]}]}`,
+		`{"rules": [{"keys": ["k"], "methods": ["services.Echo/Echo"]}]}`,
+		`{"rules": [{"key": ["k"], "methods": ["*"]}]}`,
+	} {
+		if _, err := ParsePolicy([]byte(bad)); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Package canonical produces the byte strings covered by grpc-app-auth
+// signatures.
+//
+// An encoding is a sequence of fields, each written as a 4 byte big-endian
+// length followed by the field bytes. The first two fields are always the
+// scheme version and a domain naming what is being signed, so a signature
+// produced for one purpose can never be reused for another. A request is
+// encoded as:
+//
+//	field(SchemeVersion)
+//	field(RequestDomain)
+//	field(service)        e.g. "services.Echo"
+//	field(method)         e.g. "Echo"
+//	field(timestamp)      8 byte big-endian unix seconds
+//	field(nonce)
+//	field(body)           deterministic protobuf encoding of the request
+//	field(channel binding) only for requests bound to a TLS session
+//
+// Other signatures use their own domain; see EncodeMultisig, EncodeResponse,
+// EncodeEnrollment, EncodeLogin, EncodeSession, EncodeRotation and
+// EncodeDelegation.
+//
+// The body is the deterministic protobuf wire encoding: known fields in field
+// number order and map entries sorted by key. Proto3 scalar fields holding
+// their default value are not written at all, and unknown fields are kept and
+// written again after the known ones, so a verifier whose schema lacks a field
+// the signer set only reproduces the body if that field has the highest
+// number. The body is empty for streams.
+// testdata/vectors.json holds golden vectors for implementations in other
+// languages.
+package canonical
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	SchemeVersion    = "grpc-app-auth-v1"
+	RequestDomain    = "request"
+	MultisigDomain   = "multisig"
+	ResponseDomain   = "response"
+	EnrollmentDomain = "enrollment"
+	LoginDomain      = "login"
+	SessionDomain    = "session"
+	RotationDomain   = "rotation"
+	DelegationDomain = "delegation"
+)
+
+// Request describes everything covered by a request signature.
+type Request struct {
+	// FullMethod is the gRPC method in "/package.Service/Method" form.
+	FullMethod string
+	Timestamp  int64
+	Nonce      string
+	// Message is the request, or nil for streams.
+	Message proto.Message
+	// ChannelBinding is the TLS exporter value of the connection the request
+	// is sent on, or nil if the request is not bound to one.
+	ChannelBinding []byte
+}
+
+// EncodeRequest returns the canonical encoding of r.
+func EncodeRequest(r Request) ([]byte, error) {
+	body, err := Marshal(r.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := EncodeRequestBody(r.FullMethod, r.Timestamp, r.Nonce, body)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.ChannelBinding) > 0 {
+		encoded = appendField(encoded, r.ChannelBinding)
+	}
+	return encoded, nil
+}
+
+// EncodeRequestBody is EncodeRequest for a request message that is already
+// in its deterministic encoding, as returned by Marshal.
+func EncodeRequestBody(fullMethod string, timestamp int64, nonce string, body []byte) ([]byte, error) {
+	service, method, err := SplitMethod(fullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return Encode(RequestDomain, []byte(service), []byte(method), Int64(timestamp), []byte(nonce), body), nil
+}
+
+// EncodeMultisig returns the bytes each key signs to approve a request in a
+// multi-signature envelope. They hold the same fields as EncodeRequestBody
+// under MultisigDomain, so a co-signature only counts toward a quorum and can
+// never pass as a single-key request signature.
+func EncodeMultisig(fullMethod string, timestamp int64, nonce string, body []byte) ([]byte, error) {
+	service, method, err := SplitMethod(fullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return Encode(MultisigDomain, []byte(service), []byte(method), Int64(timestamp), []byte(nonce), body), nil
+}
+
+// Response describes everything covered by a server's response signature.
+type Response struct {
+	FullMethod string
+	// Nonce is the client's response nonce, binding the response to one call.
+	Nonce   string
+	Request proto.Message
+	Message proto.Message
+}
+
+// EncodeResponse returns the canonical encoding of r:
+//
+//	field(SchemeVersion)
+//	field(ResponseDomain)
+//	field(service)
+//	field(method)
+//	field(nonce)
+//	field(request body)
+//	field(response body)
+func EncodeResponse(r Response) ([]byte, error) {
+	service, method, err := SplitMethod(r.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := Marshal(r.Request)
+	if err != nil {
+		return nil, err
+	}
+	response, err := Marshal(r.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	return Encode(ResponseDomain, []byte(service), []byte(method), []byte(r.Nonce), request, response), nil
+}
+
+// EncodeEnrollment returns the bytes a client signs to prove possession of
+// publicKey when enrolling with a server issued challenge.
+func EncodeEnrollment(challenge []byte, publicKey []byte) []byte {
+	return Encode(EnrollmentDomain, challenge, publicKey)
+}
+
+// EncodeLogin returns the bytes a client signs with the key keyID to log in
+// with a server issued challenge.
+func EncodeLogin(challenge []byte, keyID string) []byte {
+	return Encode(LoginDomain, challenge, []byte(keyID))
+}
+
+// EncodeSession returns the bytes a server signs when issuing a session token
+// with the given claims.
+func EncodeSession(claims []byte) []byte {
+	return Encode(SessionDomain, claims)
+}
+
+// EncodeRotation returns the bytes both the current key keyID and the new key
+// sign to replace keyID with newPublicKey using a server issued challenge.
+func EncodeRotation(challenge []byte, keyID string, newAlgorithm string, newPublicKey []byte) []byte {
+	return Encode(RotationDomain, challenge, []byte(keyID), []byte(newAlgorithm), newPublicKey)
+}
+
+// EncodeDelegation returns the bytes a key signs when issuing a delegation
+// certificate with the given claims.
+func EncodeDelegation(claims []byte) []byte {
+	return Encode(DelegationDomain, claims)
+}
+
+// Encode returns the scheme version, domain and fields in canonical form.
+func Encode(domain string, fields ...[]byte) []byte {
+	size := 0
+	for _, field := range fields {
+		size += 4 + len(field)
+	}
+
+	out := make([]byte, 0, 8+len(SchemeVersion)+len(domain)+size)
+	out = appendField(out, []byte(SchemeVersion))
+	out = appendField(out, []byte(domain))
+	for _, field := range fields {
+		out = appendField(out, field)
+	}
+	return out
+}
+
+// Marshal returns the deterministic protobuf encoding of msg, or nil if msg is nil.
+func Marshal(msg proto.Message) ([]byte, error) {
+	if msg == nil {
+		return nil, nil
+	}
+	return proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+}
+
+// Int64 returns v as an 8 byte big-endian field.
+func Int64(v int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(v))
+}
+
+// SplitMethod splits "/package.Service/Method" into its service and method.
+func SplitMethod(fullMethod string) (string, string, error) {
+	name := strings.TrimPrefix(fullMethod, "/")
+	i := strings.LastIndex(name, "/")
+	if !strings.HasPrefix(fullMethod, "/") || i <= 0 || i == len(name)-1 {
+		return "", "", fmt.Errorf("malformed method name %q", fullMethod)
+	}
+	return name[:i], name[i+1:], nil
+}
+
+// MatchMethod reports whether fullMethod is pattern or, if pattern ends in
+// '*', starts with the rest of pattern, e.g. "/services.Echo/*".
+func MatchMethod(pattern, fullMethod string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(fullMethod, prefix)
+	}
+	return pattern == fullMethod
+}
+
+func appendField(out []byte, field []byte) []byte {
+	out = binary.BigEndian.AppendUint32(out, uint32(len(field)))
+	return append(out, field...)
+}
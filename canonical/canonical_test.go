@@ -0,0 +1,238 @@
+package canonical
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "grpc-app-auth/services"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/vectors.json")
+
+var vectorsFile = filepath.Join("testdata", "vectors.json")
+
+// vector is a golden test case. Seed is the ed25519 private key seed used to
+// produce Signature over Encoding. Kind is "request" (the default),
+// "multisig" or "response"; for responses Message is the reply and Request the request it
+// answers. ChannelBinding is hex encoded.
+type vector struct {
+	Name           string          `json:"name"`
+	Kind           string          `json:"kind,omitempty"`
+	FullMethod     string          `json:"full_method"`
+	Timestamp      int64           `json:"timestamp,omitempty"`
+	Nonce          string          `json:"nonce"`
+	ChannelBinding string          `json:"channel_binding,omitempty"`
+	RequestType    string          `json:"request_type,omitempty"`
+	Request        json.RawMessage `json:"request,omitempty"`
+	MessageType    string          `json:"message_type,omitempty"`
+	Message        json.RawMessage `json:"message,omitempty"`
+	Encoding       string          `json:"encoding"`
+	Seed           string          `json:"seed"`
+	Signature      string          `json:"signature"`
+}
+
+// encode returns the canonical encoding described by v.
+func (v *vector) encode() ([]byte, error) {
+	msg, err := unmarshalVector(v.MessageType, v.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v.Kind {
+	case "", "request":
+		binding, err := hex.DecodeString(v.ChannelBinding)
+		if err != nil {
+			return nil, err
+		}
+		return EncodeRequest(Request{FullMethod: v.FullMethod, Timestamp: v.Timestamp, Nonce: v.Nonce, Message: msg, ChannelBinding: binding})
+	case "multisig":
+		body, err := Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		return EncodeMultisig(v.FullMethod, v.Timestamp, v.Nonce, body)
+	case "response":
+		req, err := unmarshalVector(v.RequestType, v.Request)
+		if err != nil {
+			return nil, err
+		}
+		return EncodeResponse(Response{FullMethod: v.FullMethod, Nonce: v.Nonce, Request: req, Message: msg})
+	default:
+		return nil, fmt.Errorf("unknown vector kind %q", v.Kind)
+	}
+}
+
+// unmarshalVector returns the message of the named type held in raw, or nil
+// if messageType is "".
+func unmarshalVector(messageType string, raw json.RawMessage) (proto.Message, error) {
+	if messageType == "" {
+		return nil, nil
+	}
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(messageType))
+	if err != nil {
+		return nil, err
+	}
+	msg := mt.New().Interface()
+	if err := protojson.Unmarshal(raw, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func TestGoldenVectors(t *testing.T) {
+	raw, err := os.ReadFile(vectorsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors []vector
+	if err := json.Unmarshal(raw, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range vectors {
+		v := &vectors[i]
+		t.Run(v.Name, func(t *testing.T) {
+			encoding, err := v.encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			seed, err := hex.DecodeString(v.Seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signature := ed25519.Sign(ed25519.NewKeyFromSeed(seed), encoding)
+
+			if *update {
+				v.Encoding = hex.EncodeToString(encoding)
+				v.Signature = hex.EncodeToString(signature)
+				return
+			}
+
+			if got := hex.EncodeToString(encoding); got != v.Encoding {
+				t.Errorf("encoding mismatch\n got: %s\nwant: %s", got, v.Encoding)
+			}
+			if got := hex.EncodeToString(signature); got != v.Signature {
+				t.Errorf("signature mismatch\n got: %s\nwant: %s", got, v.Signature)
+			}
+		})
+	}
+
+	if *update {
+		out, err := json.MarshalIndent(vectors, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(vectorsFile, append(out, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDomainSeparation(t *testing.T) {
+	echo, err := EncodeRequest(Request{FullMethod: "/services.Echo/Echo", Timestamp: 1, Nonce: "n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	add, err := EncodeRequest(Request{FullMethod: "/services.Add/Echo", Timestamp: 1, Nonce: "n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(echo) == string(add) {
+		t.Fatal("encodings for different services must differ")
+	}
+
+	response, err := EncodeResponse(Response{FullMethod: "/services.Echo/Echo", Nonce: "n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(echo) == string(response) {
+		t.Fatal("request and response encodings must differ")
+	}
+
+	approval, err := EncodeMultisig("/services.Echo/Echo", 1, "n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(echo) == string(approval) {
+		t.Fatal("request and multisig encodings must differ")
+	}
+
+	bound, err := EncodeRequest(Request{FullMethod: "/services.Echo/Echo", Timestamp: 1, Nonce: "n", ChannelBinding: []byte{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(echo) == string(bound) {
+		t.Fatal("encodings with and without channel binding must differ")
+	}
+}
+
+func TestMarshalUnknownAndDefaultFields(t *testing.T) {
+	// message = "" is dropped as the default; field 15 is unknown to EchoRequest.
+	unknown := protowire.AppendTag(nil, 15, protowire.VarintType)
+	unknown = protowire.AppendVarint(unknown, 7)
+
+	msg := &pb.EchoRequest{}
+	msg.ProtoReflect().SetUnknown(unknown)
+	body, err := Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(body) != hex.EncodeToString(unknown) {
+		t.Fatalf("expected only the unknown field, got %x", body)
+	}
+
+	msg.Message = "hi"
+	body, err = Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), "hi"), unknown...); hex.EncodeToString(body) != hex.EncodeToString(want) {
+		t.Fatalf("expected known fields before unknown ones, got %x", body)
+	}
+}
+
+func TestSplitMethod(t *testing.T) {
+	service, method, err := SplitMethod("/services.Echo/Echo")
+	if err != nil || service != "services.Echo" || method != "Echo" {
+		t.Fatalf("unexpected split %q %q %v", service, method, err)
+	}
+
+	for _, bad := range []string{"", "/", "services.Echo/Echo", "/services.Echo/", "/Echo"} {
+		if _, _, err := SplitMethod(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestMatchMethod(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		want    bool
+	}{
+		{"/services.Echo/Echo", true},
+		{"/services.Echo/*", true},
+		{"/services.*", true},
+		{"*", true},
+		{"/services.Echo/Ech", false},
+		{"/services.Add/*", false},
+		{"/services.Echo/Echo*", true},
+	} {
+		if got := MatchMethod(tc.pattern, "/services.Echo/Echo"); got != tc.want {
+			t.Errorf("MatchMethod(%q) = %v, want %v", tc.pattern, got, tc.want)
+		}
+	}
+}
@@ -2,90 +2,465 @@ package client
 
 import (
 	"context"
+	"crypto"
 	"crypto/ed25519"
-	"encoding/base64"
+	"crypto/tls"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
+	"grpc-app-auth/auth"
+	"grpc-app-auth/canonical"
 	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
 
-	"github.com/grpc-ecosystem/go-grpc-middleware"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/metadata"
-
-	"grpc-app-auth/utils"
 )
 
+// Client calls the grpc-app-auth services over a single connection, signing
+// each request with its key or, after Login, sending its session token. It is
+// safe for concurrent use.
 type Client struct {
-	publicKey  ed25519.PublicKey
-	privateKey ed25519.PrivateKey
+	conn           *grpc.ClientConn
+	channelBinding bool
+
+	// mu guards the fields below, which Login and key rotation change while
+	// calls may be in flight.
+	mu  sync.Mutex
+	key *clientKey
+	// next replaces key once it is trusted by the server, see RotateKey.
+	next *clientKey
+
+	// session is used instead of key once Login succeeds, until it expires.
+	session       *auth.SessionCredentials
+	sessionExpiry time.Time
+}
+
+type ClientOption func(*clientOptions) error
+
+type clientOptions struct {
+	key            *clientKey
+	tlsConfig      *tls.Config
+	channelBinding bool
+	responses      *auth.ResponseVerifier
+	logging        bool
+	dialOpts       []grpc.DialOption
+}
+
+// WithSigner signs requests with privateKey, which must be an ed25519, ECDSA
+// P-256 or RSA key. It may be any crypto.Signer, such as an
+// *agentsigner.Signer, so the private key need not be held in memory.
+func WithSigner(privateKey crypto.Signer) ClientOption {
+	return func(o *clientOptions) error {
+		key, err := newClientKey(privateKey)
+		if err != nil {
+			return err
+		}
+		o.key = &key
+		return nil
+	}
+}
+
+// WithDelegation signs with privateKey under a chain of delegation
+// certificates, the one issued by a key the server trusts first, so the key
+// need not be registered with the server.
+func WithDelegation(privateKey crypto.Signer, chain ...string) ClientOption {
+	return func(o *clientOptions) error {
+		key, err := newClientKey(privateKey)
+		if err != nil {
+			return err
+		}
+		if key.signer, err = auth.NewDelegatedSigner(privateKey, chain...); err != nil {
+			return err
+		}
+		o.key = &key
+		return nil
+	}
+}
+
+// WithTLS connects over TLS with config, such as one from
+// tlsutil.ClientConfig or tlsutil.PinnedClientConfig, instead of in plaintext.
+func WithTLS(config *tls.Config) ClientOption {
+	return func(o *clientOptions) error {
+		if config == nil {
+			return fmt.Errorf("TLS config must not be nil")
+		}
+		o.tlsConfig = config
+		return nil
+	}
+}
+
+// WithChannelBinding binds request signatures to the TLS session they are
+// sent on, see auth.Signer.WithChannelBinding. It requires WithTLS.
+func WithChannelBinding() ClientOption {
+	return func(o *clientOptions) error {
+		o.channelBinding = true
+		return nil
+	}
+}
+
+// WithServerKey requires every response to be signed by serverKey, the
+// server's identity key. Calls whose response is unsigned or altered fail.
+func WithServerKey(serverKey ed25519.PublicKey) ClientOption {
+	return func(o *clientOptions) error {
+		if len(serverKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("server key must be an ed25519 public key")
+		}
+		o.responses = auth.NewResponseVerifier(serverKey)
+		return nil
+	}
+}
+
+// WithLogging logs every request and response.
+func WithLogging() ClientOption {
+	return func(o *clientOptions) error {
+		o.logging = true
+		return nil
+	}
+}
+
+// WithDialOptions passes extra options to grpc.Dial.
+func WithDialOptions(opts ...grpc.DialOption) ClientOption {
+	return func(o *clientOptions) error {
+		o.dialOpts = append(o.dialOpts, opts...)
+		return nil
+	}
 }
 
-func NewClient() *Client {
-	return &Client{}
+// Dial creates a client for the server at target, e.g. "localhost:50051" or
+// "unix:///run/grpc-app-auth.sock". Like grpc.Dial it does not wait for the
+// connection to be established. The client must be closed with Close.
+func Dial(target string, opts ...ClientOption) (*Client, error) {
+	// apply defaults
+	o := &clientOptions{}
+
+	// apply user options
+	for _, opt := range opts {
+		err := opt(o)
+		if err != nil {
+			return nil, fmt.Errorf("error applying option: %w", err)
+		}
+	}
+
+	if o.channelBinding && o.tlsConfig == nil {
+		return nil, fmt.Errorf("channel binding requires TLS")
+	}
+
+	c := &Client{key: o.key, channelBinding: o.channelBinding}
+
+	interceptors := []grpc.UnaryClientInterceptor{otelgrpc.UnaryClientInterceptor()}
+	if o.logging {
+		interceptors = append(interceptors, loggingUnaryClientInterceptor)
+	}
+	if o.responses != nil {
+		interceptors = append(interceptors, o.responses.UnaryClientInterceptor())
+	}
+	interceptors = append(interceptors, auth.RequestUnaryClientInterceptor())
+
+	dialOpts := []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(interceptors...),
+		grpc.WithPerRPCCredentials(callCredentials{c}),
+	}
+	if o.tlsConfig != nil {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(o.tlsConfig)))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	conn, err := grpc.Dial(target, append(dialOpts, o.dialOpts...)...)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return c, nil
 }
 
-func NewClientWithKeys(publicKey ed25519.PublicKey, privateKey ed25519.PrivateKey) *Client {
-	return &Client{publicKey: publicKey, privateKey: privateKey}
+// Close closes the client's connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
 }
 
-func (c *Client) Echo(message string) {
-	conn, err := grpc.Dial("localhost:50051",
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithUnaryInterceptor(
-			grpc_middleware.ChainUnaryClient(
-				otelgrpc.UnaryClientInterceptor(),
-				loggingUnaryClientInterceptor,
-			),
-		),
-	)
+// clientKey is a key the client signs with.
+type clientKey struct {
+	// publicKey is the key as stored by the server: raw for ed25519, PKIX DER
+	// for other algorithms.
+	publicKey  []byte
+	algorithm  signing.Algorithm
+	privateKey crypto.Signer
+	keyID      string
+	signer     *auth.Signer
+}
 
+func newClientKey(privateKey crypto.Signer) (clientKey, error) {
+	publicKey, alg, err := signing.MarshalPublicKey(privateKey.Public())
 	if err != nil {
-		log.Fatalf("did not connect: %v", err)
+		return clientKey{}, err
 	}
-	defer conn.Close()
 
-	grpcClient := pb.NewEchoClient(conn)
+	keyID := signing.Fingerprint(alg, publicKey)
+	return clientKey{
+		publicKey:  publicKey,
+		algorithm:  alg,
+		privateKey: privateKey,
+		keyID:      keyID,
+		signer:     auth.NewSigner(keyID, privateKey),
+	}, nil
+}
 
-	signature := ed25519.Sign(c.privateKey, []byte(message))
+// KeyID returns the ID the server knows the client's key by, its fingerprint,
+// or "" if the client has no key.
+func (c *Client) KeyID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.key == nil {
+		return ""
+	}
+	return c.key.keyID
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
+// PublicKey returns the client's public key as the server stores it: raw for
+// ed25519, PKIX DER for other algorithms.
+func (c *Client) PublicKey() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.key == nil {
+		return nil
+	}
+	return c.key.publicKey
+}
 
-	pubKeyStr := base64.StdEncoding.EncodeToString(c.publicKey)
-	r, err := grpcClient.Echo(ctx, &pb.EchoRequest{Message: message, PublicKey: pubKeyStr, Signature: signature})
+// SetNextKey sets the key that replaces the current one when RotateKey or
+// UseNextKey is called. Until then requests are signed with the current key.
+func (c *Client) SetNextKey(privateKey crypto.Signer) error {
+	key, err := newClientKey(privateKey)
 	if err != nil {
-		log.Fatalf("could not greet: %v", err)
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.next = &key
+	return nil
+}
+
+// NextKeyID returns the ID of the key set with SetNextKey, or "" if there is
+// none.
+func (c *Client) NextKeyID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.next == nil {
+		return ""
+	}
+	return c.next.keyID
+}
+
+// UseNextKey signs with the next key from now on, for when it was trusted by
+// other means such as KeyAdmin. Any session from Login is dropped, since it
+// belongs to the old key.
+func (c *Client) UseNextKey() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.next == nil {
+		return fmt.Errorf("no next key is set")
 	}
-	log.Printf("Greeting: %s", r.Message)
+	c.key = c.next
+	c.next = nil
+	c.session = nil
+	return nil
 }
 
-func (c *Client) Add(a float64, b float64) {
-	conn, err := grpc.Dial("localhost:50051", grpc.WithTransportCredentials(insecure.NewCredentials()))
+// Echo returns the server's echo of message.
+func (c *Client) Echo(ctx context.Context, message string) (string, error) {
+	r, err := pb.NewEchoClient(c.conn).Echo(ctx, &pb.EchoRequest{Message: message})
 	if err != nil {
-		log.Fatalf("did not connect: %v", err)
+		return "", err
 	}
-	defer conn.Close()
+	return r.Message, nil
+}
 
-	grpcClient := pb.NewAddClient(conn)
+// Add returns the sum of a and b as computed by the server.
+func (c *Client) Add(ctx context.Context, a float64, b float64) (float64, error) {
+	r, err := pb.NewAddClient(c.conn).Add(ctx, &pb.AddRequest{A: a, B: b})
+	if err != nil {
+		return 0, err
+	}
+	return r.Result, nil
+}
 
-	signature := ed25519.Sign(c.privateKey, []byte(utils.AddCanonicalization(a, b)))
+// Enroll asks the server to trust the client's key, proving possession of it
+// by signing a server issued challenge. token is checked by the server's
+// enrollment policy and may be empty. It reports whether the key was approved
+// straight away; otherwise it is pending until an admin approves it.
+func (c *Client) Enroll(ctx context.Context, label string, token string) (approved bool, err error) {
+	key, err := c.currentKey()
+	if err != nil {
+		return false, err
+	}
 
-	signatureStr := base64.StdEncoding.EncodeToString(signature)
-	pubKeyStr := base64.StdEncoding.EncodeToString(c.publicKey)
+	grpcClient := pb.NewEnrollmentClient(c.conn)
+	ch, err := grpcClient.GetChallenge(ctx, &pb.ChallengeRequest{})
+	if err != nil {
+		return false, err
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
+	signature, _, err := signing.Sign(key.privateKey, canonical.EncodeEnrollment(ch.Challenge, key.publicKey))
+	if err != nil {
+		return false, fmt.Errorf("could not sign challenge: %w", err)
+	}
+	r, err := grpcClient.Enroll(ctx, &pb.EnrollRequest{
+		PublicKey:       key.publicKey,
+		Algorithm:       string(key.algorithm),
+		Label:           label,
+		Challenge:       ch.Challenge,
+		Signature:       signature,
+		EnrollmentToken: token,
+	})
+	if err != nil {
+		return false, err
+	}
+	return r.Approved, nil
+}
 
-	ctx = metadata.AppendToOutgoingContext(ctx, "signature", signatureStr, "key", pubKeyStr)
+// Login signs a server issued challenge in exchange for a session token, which
+// later calls send instead of signing each request until it expires. No
+// scopes requests access to every method. It returns the token's expiry.
+func (c *Client) Login(ctx context.Context, scopes ...string) (time.Time, error) {
+	key, err := c.currentKey()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	grpcClient := pb.NewSessionClient(c.conn)
+	ch, err := grpcClient.GetLoginChallenge(ctx, &pb.ChallengeRequest{})
+	if err != nil {
+		return time.Time{}, err
+	}
 
-	r, err := grpcClient.Add(ctx, &pb.AddRequest{A: a, B: b})
+	signature, _, err := signing.Sign(key.privateKey, canonical.EncodeLogin(ch.Challenge, key.keyID))
 	if err != nil {
-		log.Fatalf("could not add: %v", err)
+		return time.Time{}, fmt.Errorf("could not sign challenge: %w", err)
 	}
-	log.Printf("Result: %v", r.Result)
+	r, err := grpcClient.Login(ctx, &pb.LoginRequest{
+		KeyId:     key.keyID,
+		Challenge: ch.Challenge,
+		Signature: signature,
+		Scopes:    scopes,
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	expiry := time.Unix(r.ExpiresAt, 0)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// A token for a key rotated away while logging in is not used.
+	if c.key == key {
+		c.session = auth.NewSessionCredentials(r.Token)
+		c.sessionExpiry = expiry
+	}
+	return expiry, nil
+}
+
+// RotateKey asks the server to replace the current key with the one set by
+// SetNextKey, then signs with the new key. The current key endorses the new
+// one and the new key proves possession, both by signing a server issued
+// challenge. The server keeps the old key valid for its grace period, so
+// requests already signed with it still succeed. RotateKey returns the time
+// the server stops accepting the old key.
+func (c *Client) RotateKey(ctx context.Context) (time.Time, error) {
+	c.mu.Lock()
+	key, next := c.key, c.next
+	c.mu.Unlock()
+	if key == nil {
+		return time.Time{}, fmt.Errorf("client has no key")
+	}
+	if next == nil {
+		return time.Time{}, fmt.Errorf("no next key is set")
+	}
+
+	grpcClient := pb.NewKeyRotationClient(c.conn)
+	ch, err := grpcClient.GetRotationChallenge(ctx, &pb.ChallengeRequest{})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	statement := canonical.EncodeRotation(ch.Challenge, key.keyID, string(next.algorithm), next.publicKey)
+	endorsement, _, err := signing.Sign(key.privateKey, statement)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not endorse new key: %w", err)
+	}
+	proof, _, err := signing.Sign(next.privateKey, statement)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not sign with new key: %w", err)
+	}
+	r, err := grpcClient.RotateKey(ctx, &pb.RotateKeyRequest{
+		KeyId:        key.keyID,
+		NewPublicKey: next.publicKey,
+		NewAlgorithm: string(next.algorithm),
+		Challenge:    ch.Challenge,
+		Endorsement:  endorsement,
+		Proof:        proof,
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.key = next
+	if c.next == next {
+		c.next = nil
+	}
+	c.session = nil
+	return time.Unix(r.OldKeyExpiresAt, 0), nil
+}
+
+func (c *Client) currentKey() (*clientKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.key == nil {
+		return nil, fmt.Errorf("client has no key")
+	}
+	return c.key, nil
+}
+
+// currentCredentials returns the session or signer the client authenticates
+// with right now, or nil if it has neither.
+func (c *Client) currentCredentials() credentials.PerRPCCredentials {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session != nil && time.Now().Before(c.sessionExpiry) {
+		return c.session
+	}
+	if c.key == nil {
+		return nil
+	}
+	if c.channelBinding {
+		return c.key.signer.WithChannelBinding()
+	}
+	return c.key.signer
+}
+
+// callCredentials authenticates each call with the client's current
+// credentials, so Login and key rotation take effect on the open connection.
+type callCredentials struct {
+	c *Client
+}
+
+func (cc callCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	creds := cc.c.currentCredentials()
+	if creds == nil {
+		return nil, nil
+	}
+	return creds.GetRequestMetadata(ctx, uri...)
+}
+
+func (cc callCredentials) RequireTransportSecurity() bool {
+	return false
 }
 
 func loggingUnaryClientInterceptor(
@@ -0,0 +1,213 @@
+// Package delegation issues and verifies certificates by which a key
+// delegates part of its authority to another key.
+//
+// A certificate is the base64url encoded JSON claims and the base64url
+// encoded signature over their canonical delegation encoding, joined by a
+// '.', like a session token. Certificates form a chain: the first is issued
+// by a trusted key and each later one by the subject of the one before it. A
+// chain only grants the methods every certificate in it allows, until the
+// first of them expires, so each link can only narrow the authority it was
+// given.
+package delegation
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"grpc-app-auth/canonical"
+	"grpc-app-auth/signing"
+)
+
+// MaxChainLength is the largest number of certificates in a chain.
+const MaxChainLength = 4
+
+var (
+	ErrMalformedCertificate = errors.New("malformed delegation certificate")
+	ErrInvalidCertificate   = errors.New("delegation certificate signature is not valid")
+	ErrExpiredCertificate   = errors.New("delegation certificate has expired")
+	ErrBrokenChain          = errors.New("delegation certificate was not issued by the previous subject")
+)
+
+// Claims are the contents of a delegation certificate.
+type Claims struct {
+	// Issuer is the key ID of the key that signed the certificate.
+	Issuer string `json:"iss"`
+	// SubjectKey is the delegated public key: raw for ed25519, PKIX DER for
+	// other algorithms.
+	SubjectKey       []byte            `json:"sub"`
+	SubjectAlgorithm signing.Algorithm `json:"alg,omitempty"`
+	// Methods are full method names the subject may call. A method ending in
+	// '*' matches every method with that prefix, e.g. "/services.Echo/*".
+	Methods   []string `json:"methods"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+// SubjectKeyID returns the key ID of the subject key.
+func (c *Claims) SubjectKeyID() string {
+	return signing.Fingerprint(c.SubjectAlgorithm, c.SubjectKey)
+}
+
+// Allows reports whether the claims grant access to fullMethod.
+func (c *Claims) Allows(fullMethod string) bool {
+	for _, method := range c.Methods {
+		if canonical.MatchMethod(method, fullMethod) {
+			return true
+		}
+	}
+	return false
+}
+
+// Certificate is a parsed delegation certificate whose signature has not
+// necessarily been checked.
+type Certificate struct {
+	Claims
+	payload   []byte
+	signature []byte
+}
+
+// Issue returns a certificate signed by issuer delegating methods to subject
+// for ttl. issuer may be any crypto.Signer for a supported algorithm.
+func Issue(issuer crypto.Signer, subject crypto.PublicKey, methods []string, ttl time.Duration) (string, *Claims, error) {
+	if len(methods) == 0 {
+		return "", nil, fmt.Errorf("at least one method must be delegated")
+	}
+	if ttl <= 0 {
+		return "", nil, fmt.Errorf("delegation ttl must be positive")
+	}
+
+	issuerKeyID, err := signing.PublicKeyFingerprint(issuer.Public())
+	if err != nil {
+		return "", nil, err
+	}
+	subjectKey, subjectAlg, err := signing.MarshalPublicKey(subject)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims := Claims{
+		Issuer:           issuerKeyID,
+		SubjectKey:       subjectKey,
+		SubjectAlgorithm: subjectAlg,
+		Methods:          methods,
+		IssuedAt:         now.Unix(),
+		ExpiresAt:        now.Add(ttl).Unix(),
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	signature, _, err := signing.Sign(issuer, canonical.EncodeDelegation(payload))
+	if err != nil {
+		return "", nil, err
+	}
+
+	certificate := base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return certificate, &claims, nil
+}
+
+// Parse decodes a certificate without checking its signature.
+func Parse(certificate string) (*Certificate, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(certificate, ".")
+	if !ok {
+		return nil, ErrMalformedCertificate
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrMalformedCertificate
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, ErrMalformedCertificate
+	}
+
+	c := &Certificate{payload: payload, signature: signature}
+	if err := json.Unmarshal(payload, &c.Claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedCertificate, err)
+	}
+	if _, err := signing.ParsePublicKey(c.SubjectAlgorithm, c.SubjectKey); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedCertificate, err)
+	}
+	return c, nil
+}
+
+// Chain is a chain of certificates, the one issued by the trusted key first.
+type Chain []*Certificate
+
+// ParseChain decodes certificates joined by ',' as produced by JoinChain.
+func ParseChain(certificates string) (Chain, error) {
+	parts := strings.Split(certificates, ",")
+	if len(parts) > MaxChainLength {
+		return nil, fmt.Errorf("%w: chain is longer than %d certificates", ErrMalformedCertificate, MaxChainLength)
+	}
+
+	chain := make(Chain, 0, len(parts))
+	for _, part := range parts {
+		c, err := Parse(part)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, c)
+	}
+	return chain, nil
+}
+
+// JoinChain joins certificates, the one issued by the trusted key first, for
+// sending as a single metadata value.
+func JoinChain(certificates ...string) string {
+	return strings.Join(certificates, ",")
+}
+
+// Root returns the key ID of the key that issued the first certificate.
+func (ch Chain) Root() string {
+	return ch[0].Issuer
+}
+
+// Leaf returns the claims of the last certificate, whose subject may sign
+// requests under the chain.
+func (ch Chain) Leaf() *Claims {
+	return &ch[len(ch)-1].Claims
+}
+
+// Verify checks that the first certificate was signed by rootKey, each later
+// one by the subject of the one before it, and that none has expired.
+func (ch Chain) Verify(rootAlg signing.Algorithm, rootKey []byte, now time.Time) error {
+	if len(ch) == 0 {
+		return ErrMalformedCertificate
+	}
+
+	alg, key := rootAlg, rootKey
+	for _, c := range ch {
+		if c.Issuer != signing.Fingerprint(alg, key) {
+			return ErrBrokenChain
+		}
+		if signing.Verify(alg, key, canonical.EncodeDelegation(c.payload), c.signature) != nil {
+			return ErrInvalidCertificate
+		}
+		if !now.Before(time.Unix(c.ExpiresAt, 0)) {
+			return ErrExpiredCertificate
+		}
+		alg, key = c.SubjectAlgorithm, c.SubjectKey
+	}
+	return nil
+}
+
+// Allows reports whether every certificate in the chain grants fullMethod.
+func (ch Chain) Allows(fullMethod string) bool {
+	for _, c := range ch {
+		if !c.Allows(fullMethod) {
+			return false
+		}
+	}
+	return len(ch) > 0
+}
@@ -0,0 +1,117 @@
+package delegation
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-app-auth/signing"
+)
+
+func TestChain(t *testing.T) {
+	rootPublicKey, rootPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _, err := Issue(rootPrivateKey, workerKey.Public(), []string{"/services.Echo/*", "/services.Add/Add"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, claims, err := Issue(workerKey, taskPublicKey, []string{"/services.Echo/Echo", "/services.Add/Add", "/services.KeyAdmin/*"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.SubjectKeyID() != signing.Fingerprint(signing.Ed25519, taskPublicKey) {
+		t.Fatalf("unexpected subject %s", claims.SubjectKeyID())
+	}
+
+	chain, err := ParseChain(JoinChain(first, second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain.Root() != signing.Fingerprint(signing.Ed25519, rootPublicKey) {
+		t.Fatalf("unexpected root %s", chain.Root())
+	}
+	if err := chain.Verify(signing.Ed25519, rootPublicKey, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second certificate cannot widen what the first granted.
+	if !chain.Allows("/services.Echo/Echo") || !chain.Allows("/services.Add/Add") || chain.Allows("/services.KeyAdmin/ListKeys") {
+		t.Fatal("unexpected scope evaluation")
+	}
+
+	if err := chain.Verify(signing.Ed25519, rootPublicKey, time.Now().Add(2*time.Minute)); !errors.Is(err, ErrExpiredCertificate) {
+		t.Fatalf("expected expired certificate, got %v", err)
+	}
+
+	otherPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.Verify(signing.Ed25519, otherPublicKey, time.Now()); !errors.Is(err, ErrBrokenChain) {
+		t.Fatalf("expected broken chain for another root, got %v", err)
+	}
+
+	// Skipping the middle certificate breaks the chain.
+	skipped, err := ParseChain(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := skipped.Verify(signing.Ed25519, rootPublicKey, time.Now()); !errors.Is(err, ErrBrokenChain) {
+		t.Fatalf("expected broken chain, got %v", err)
+	}
+}
+
+func TestForgedCertificate(t *testing.T) {
+	rootPublicKey, rootPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subjectPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, _, err := Issue(rootPrivateKey, subjectPublicKey, []string{"/services.Echo/Echo"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Swap in claims granting every method, keeping the original signature.
+	_, signature, _ := strings.Cut(certificate, ".")
+	widened, _, err := Issue(rootPrivateKey, subjectPublicKey, []string{"*"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _, _ := strings.Cut(widened, ".")
+
+	chain, err := ParseChain(payload + "." + signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.Verify(signing.Ed25519, rootPublicKey, time.Now()); !errors.Is(err, ErrInvalidCertificate) {
+		t.Fatalf("expected invalid certificate, got %v", err)
+	}
+
+	if _, err := ParseChain(strings.Repeat(certificate+",", MaxChainLength) + certificate); err == nil {
+		t.Fatal("expected an overlong chain to be rejected")
+	}
+	if _, _, err := Issue(rootPrivateKey, subjectPublicKey, nil, time.Hour); err == nil {
+		t.Fatal("expected a certificate without methods to be rejected")
+	}
+}
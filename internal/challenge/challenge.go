@@ -0,0 +1,81 @@
+package challenge
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+)
+
+const challengeSize = 32
+
+var ErrStoreFull = errors.New("too many outstanding challenges")
+
+// Store issues random single-use challenges that expire after a TTL.
+type Store struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]time.Time // challenge -> expiry
+}
+
+func NewStore(ttl time.Duration, maxEntries int) *Store {
+	return &Store{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		pending:    make(map[string]time.Time),
+	}
+}
+
+// Issue returns a new challenge and the time it expires.
+func (s *Store) Issue() ([]byte, time.Time, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	now := s.now()
+	expiry := now.Add(s.ttl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) >= s.maxEntries {
+		s.prune(now)
+		if len(s.pending) >= s.maxEntries {
+			return nil, time.Time{}, ErrStoreFull
+		}
+	}
+
+	s.pending[base64.StdEncoding.EncodeToString(challenge)] = expiry
+	return challenge, expiry, nil
+}
+
+// Consume reports whether the challenge was issued and has not expired. A
+// challenge can only be consumed once.
+func (s *Store) Consume(challenge []byte) bool {
+	key := base64.StdEncoding.EncodeToString(challenge)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry, ok := s.pending[key]
+	if !ok {
+		return false
+	}
+
+	delete(s.pending, key)
+	return s.now().Before(expiry)
+}
+
+func (s *Store) prune(now time.Time) {
+	for challenge, expiry := range s.pending {
+		if !now.Before(expiry) {
+			delete(s.pending, challenge)
+		}
+	}
+}
@@ -1,22 +1,96 @@
 package main
 
 import (
-	"crypto/ed25519"
+	"context"
+	"crypto"
+	"encoding/base64"
+	"errors"
+	"grpc-app-auth/agentsigner"
 	"grpc-app-auth/client"
 	"grpc-app-auth/internal/keyutils"
+	"log"
+	"os"
+	"time"
 )
 
+const (
+	passphraseEnv = "PRIVATE_KEY_PASSPHRASE"
+	keyName       = "example"
+)
+
+// keyDir is where the example key pair is kept, $KEY_DIR or ../fixtures.
+func keyDir() string {
+	if dir := os.Getenv("KEY_DIR"); dir != "" {
+		return dir
+	}
+	return "../fixtures"
+}
+
+// serverAddress is the server to call, $SERVER_ADDRESS or localhost:50051.
+func serverAddress() string {
+	if address := os.Getenv("SERVER_ADDRESS"); address != "" {
+		return address
+	}
+	return "localhost:50051"
+}
+
 func main() {
-	var pubKey ed25519.PublicKey
-	var privKey ed25519.PrivateKey
-	err := keyutils.ReadKeysFromFiles("public.key", &pubKey, "private.key", &privKey)
+	var signer crypto.Signer
+	useAgent := os.Getenv("USE_SIGNING_AGENT") == "true"
+	if useAgent {
+		// Sign with the first ed25519 or ECDSA P-256 key in ssh-agent.
+		agentSigner, err := agentsigner.NewFromEnv(nil)
+		if err != nil {
+			panic(err)
+		}
+		signer = agentSigner
+	} else {
+		ring, err := keyutils.NewKeyRing(keyDir())
+		if err != nil {
+			panic(err)
+		}
+
+		privKey, err := ring.PrivateKey(keyName, nil)
+		if errors.Is(err, keyutils.ErrPassphraseRequired) {
+			passphrase, err := keyutils.ReadPassphrase(passphraseEnv)
+			if err != nil {
+				panic(err)
+			}
+			privKey, err = ring.PrivateKey(keyName, passphrase)
+			if err != nil {
+				panic(err)
+			}
+		} else if err != nil {
+			panic(err)
+		}
+
+		signer = privKey
+	}
+
+	c, err := client.Dial(serverAddress(), client.WithSigner(signer), client.WithLogging())
 	if err != nil {
 		panic(err)
 	}
+	defer c.Close()
 
-	c := client.NewClientWithKeys(pubKey, privKey)
+	if useAgent {
+		log.Printf("Signing with agent key %s (public key %s)", c.KeyID(), base64.StdEncoding.EncodeToString(c.PublicKey()))
+	} else {
+		log.Printf("Signing as key %s", c.KeyID())
+	}
 
-	c.Echo("Hello World")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	c.Add(1, 2)
+	greeting, err := c.Echo(ctx, "Hello World")
+	if err != nil {
+		log.Fatalf("could not greet: %v", err)
+	}
+	log.Printf("Greeting: %s", greeting)
+
+	sum, err := c.Add(ctx, 1, 2)
+	if err != nil {
+		log.Fatalf("could not add: %v", err)
+	}
+	log.Printf("Result: %v", sum)
 }
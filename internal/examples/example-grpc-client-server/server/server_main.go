@@ -1,58 +1,115 @@
 package main
 
 import (
-	"crypto/ed25519"
-	"encoding/base64"
-	"grpc-app-auth/internal"
+	"context"
+	"errors"
+	"grpc-app-auth/authz"
 	"grpc-app-auth/internal/keyutils"
+	"grpc-app-auth/keystore"
 	"grpc-app-auth/server"
+	"grpc-app-auth/signing"
 	"log"
 	"os"
-	"sync"
-	"time"
+	"os/signal"
+	"syscall"
 )
 
+const (
+	passphraseEnv = "PRIVATE_KEY_PASSPHRASE"
+	keyName       = "example"
+)
+
+// keyDir is where the example key pair is kept, $KEY_DIR or ../fixtures.
+func keyDir() string {
+	if dir := os.Getenv("KEY_DIR"); dir != "" {
+		return dir
+	}
+	return "../fixtures"
+}
+
 func main() {
-	var pubKey ed25519.PublicKey
-	var privKey ed25519.PrivateKey
-	err := keyutils.ReadKeysFromFiles("public.key", &pubKey, "private.key", &privKey)
+	ring, err := keyutils.NewKeyRing(keyDir())
 	if err != nil {
-		publicKey, privateKey, err := ed25519.GenerateKey(nil)
+		log.Fatalf("Error opening key directory: %v", err)
+	}
+
+	// The server only needs the example client's public key, so the private
+	// key is never read here.
+	pubKey, err := ring.PublicKey(keyName)
+	if errors.Is(err, os.ErrNotExist) {
+		// The private key is encrypted if a passphrase is set.
+		privKey, err := ring.Generate(keyName, []byte(os.Getenv(passphraseEnv)))
 		if err != nil {
 			log.Fatalf("Error generating keys: %v", err)
 		}
+		pubKey = privKey.Public()
+	} else if err != nil {
+		log.Fatalf("Error reading keys: %v", err)
+	}
 
-		publicKeyPair := &keyutils.FileKeyPair{FileName: "public.key", Key: publicKey}
-		privateKeyPair := &keyutils.FileKeyPair{FileName: "private.key", Key: privateKey}
+	pubKeyBytes, alg, err := signing.MarshalPublicKey(pubKey)
+	if err != nil {
+		log.Fatalf("Error encoding public key: %v", err)
+	}
 
-		if err := keyutils.SaveKeysToFiles(publicKeyPair, privateKeyPair); err != nil {
-			log.Fatalf("Error writing keys: %v", err)
+	var tks keystore.KeyStore
+	if authorizedKeysFile := os.Getenv("AUTHORIZED_KEYS_FILE"); authorizedKeysFile != "" {
+		fks, err := keystore.NewFileKeyStore(authorizedKeysFile)
+		if err != nil {
+			log.Fatalf("Error loading authorized keys: %v", err)
 		}
-		pubKey = publicKey
+		defer fks.Close()
+		tks = fks
+	} else {
+		mks := keystore.NewMemoryKeyStore()
+		mks.StoreKeyRecord(keystore.KeyRecord{
+			ID:        signing.Fingerprint(alg, pubKeyBytes),
+			PublicKey: pubKeyBytes,
+			Algorithm: alg,
+		})
+		tks = mks
 	}
 
-	tks := &internal.TrustedKeyStore{Keys: map[string]ed25519.PublicKey{}}
-	tks.StorePublicKey(base64.StdEncoding.EncodeToString(pubKey), pubKey)
-
 	enableTelemetry := os.Getenv("ENABLE_TELEMETRY")
 	telemetryTarget := os.Getenv("TELEMETRY_TARGET")
 
-	opts := make([]server.ServerOption, 0, 1)
+	opts := make([]server.ServerOption, 0, 2)
 	if enableTelemetry == "true" && telemetryTarget != "" {
 		opts = append(opts, server.WithOpenTelemetry(telemetryTarget))
 	}
 
+	if address := os.Getenv("LISTEN_ADDRESS"); address != "" {
+		opts = append(opts, server.WithAddress(address))
+	}
+
+	if policyFile := os.Getenv("AUTHORIZATION_POLICY_FILE"); policyFile != "" {
+		policy, err := authz.LoadPolicyFile(policyFile)
+		if err != nil {
+			log.Fatalf("Error loading authorization policy: %v", err)
+		}
+		opts = append(opts, server.WithAuthorizer(policy))
+	}
+
 	s, err := server.NewServerWithTrustedKeysAndFuncOpts(tks, opts...)
 	if err != nil {
 		log.Fatalf("Error creating server: %v", err)
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(1)
+	// Shut down gracefully on Ctrl-C or when the container is stopped.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	go func() {
-		defer wg.Done()
-		s.Serve()
+		<-s.Ready()
+		// Orchestrators can probe for this file to know the server is listening.
+		if readyFile := os.Getenv("READY_FILE"); readyFile != "" {
+			if err := os.WriteFile(readyFile, nil, 0644); err != nil {
+				log.Printf("Error writing ready file: %v", err)
+			}
+		}
 	}()
-	time.AfterFunc(300*time.Second, s.Stop)
-	wg.Wait()
+
+	if err := s.Run(ctx); err != nil {
+		log.Fatalf("Error serving: %v", err)
+	}
 }
@@ -0,0 +1,66 @@
+package intgtest
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"grpc-app-auth/auth"
+	"grpc-app-auth/keystore"
+	server "grpc-app-auth/server"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+)
+
+func TestSignatureAlgorithms(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, signing.MinRSAKeySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tks := keystore.NewMemoryKeyStore()
+	trust := func(key crypto.Signer) string {
+		publicKey, alg, err := signing.MarshalPublicKey(key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyID := signing.Fingerprint(alg, publicKey)
+		if err := tks.StoreKeyRecord(keystore.KeyRecord{ID: keyID, PublicKey: publicKey, Algorithm: alg}); err != nil {
+			t.Fatal(err)
+		}
+		return keyID
+	}
+	ecKeyID := trust(ecKey)
+	rsaKeyID := trust(rsaKey)
+
+	s := newServer(t, tks,
+		server.WithAllowedAlgorithms(signing.Ed25519, signing.ECDSAP256SHA256))
+	startServer(t, s)
+
+	ecClient := pb.NewAddClient(dialWithSigner(t, s, auth.NewSigner(ecKeyID, ecKey)))
+	if _, err := ecClient.Add(context.Background(), &pb.AddRequest{A: 1, B: 2}); err != nil {
+		t.Fatalf("ECDSA signed request failed: %v", err)
+	}
+
+	// RSA-PSS is supported but not on this server's allowlist.
+	rsaClient := pb.NewAddClient(dialWithSigner(t, s, auth.NewSigner(rsaKeyID, rsaKey)))
+	_, err = rsaClient.Add(context.Background(), &pb.AddRequest{A: 1, B: 2})
+	requireReason(t, err, auth.ReasonDisallowedAlgorithm)
+
+	// A signature by a different key of the right algorithm is still rejected.
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := pb.NewAddClient(dialWithSigner(t, s, auth.NewSigner(ecKeyID, otherKey)))
+	_, err = forged.Add(context.Background(), &pb.AddRequest{A: 1, B: 2})
+	requireReason(t, err, auth.ReasonInvalidSignature)
+}
@@ -0,0 +1,276 @@
+package intgtest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"grpc-app-auth/auth"
+	"grpc-app-auth/keystore"
+	server "grpc-app-auth/server"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestReplayedRequestIsRejected(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := signing.Fingerprint(signing.Ed25519, publicKey)
+
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(keyID, publicKey)
+
+	s := newServer(t, tks, server.WithReplayWindow(5*time.Second))
+	conn := startServer(t, s)
+	addClient := pb.NewAddClient(conn)
+
+	signedContext := func(timestamp time.Time, nonce string) context.Context {
+		md, err := auth.Sign(privateKey, keyID, "/services.Add/Add", &pb.AddRequest{A: 1, B: 2}, timestamp, nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return metadata.NewOutgoingContext(context.Background(), md)
+	}
+
+	ctx := signedContext(time.Now(), "nonce-1")
+	if _, err := addClient.Add(ctx, &pb.AddRequest{A: 1, B: 2}); err != nil {
+		t.Fatalf("first request failed: %v", err)
+	}
+
+	_, err = addClient.Add(ctx, &pb.AddRequest{A: 1, B: 2})
+	requireReason(t, err, auth.ReasonReplayedNonce)
+
+	stale := signedContext(time.Now().Add(-time.Minute), "nonce-2")
+	_, err = addClient.Add(stale, &pb.AddRequest{A: 1, B: 2})
+	requireReason(t, err, auth.ReasonStaleTimestamp)
+}
+
+// newServer returns a server for tks that listens on a free local port.
+func newServer(t *testing.T, tks keystore.KeyStore, opts ...server.ServerOption) *server.Server {
+	t.Helper()
+	s, err := server.NewServerWithTrustedKeysAndFuncOpts(tks, append([]server.ServerOption{server.WithAddress("127.0.0.1:0")}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// serve serves s in the background until the test ends and waits until it is
+// listening.
+func serve(t *testing.T, s *server.Server) {
+	t.Helper()
+	errs := make(chan error, 1)
+	go func() { errs <- s.Serve() }()
+	t.Cleanup(func() {
+		s.Stop()
+		if err := <-errs; err != nil {
+			t.Errorf("serve failed: %v", err)
+		}
+	})
+
+	select {
+	case <-s.Ready():
+	case err := <-errs:
+		errs <- err
+		t.Fatalf("server did not start: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not start")
+	}
+}
+
+// startServer serves s in the background and returns a connection once it is listening.
+func startServer(t *testing.T, s *server.Server) *grpc.ClientConn {
+	t.Helper()
+	serve(t, s)
+
+	conn, err := grpc.Dial(s.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func requireReason(t *testing.T, err error, reason string) {
+	t.Helper()
+	st := status.Convert(err)
+	if st.Code() != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Reason == reason {
+			return
+		}
+	}
+	t.Fatalf("expected reason %s, got %v", reason, st.Details())
+}
+
+func TestTamperedRequestIsRejected(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := signing.Fingerprint(signing.Ed25519, publicKey)
+
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(keyID, publicKey)
+
+	conn := startServer(t, newServer(t, tks))
+	addClient := pb.NewAddClient(conn)
+
+	ctx, err := auth.AppendSignature(context.Background(), privateKey, keyID, "/services.Add/Add", &pb.AddRequest{A: 1, B: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = addClient.Add(ctx, &pb.AddRequest{A: 1, B: 3})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestSignerAuthenticatesGeneratedStubs(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := signing.Fingerprint(signing.Ed25519, publicKey)
+
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(keyID, publicKey)
+
+	s := newServer(t, tks)
+	startServer(t, s)
+
+	conn := dialWithSigner(t, s, auth.NewSigner(keyID, privateKey))
+
+	echo, err := pb.NewEchoClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+	if err != nil {
+		t.Fatalf("echo failed: %v", err)
+	}
+	if echo.Message != "Echo hi" {
+		t.Fatalf("unexpected echo reply %q", echo.Message)
+	}
+
+	sum, err := pb.NewAddClient(conn).Add(context.Background(), &pb.AddRequest{A: 1, B: 2})
+	if err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if sum.Result != 3 {
+		t.Fatalf("unexpected sum %v", sum.Result)
+	}
+}
+
+func TestKeyValidityReasons(t *testing.T) {
+	tks := keystore.NewMemoryKeyStore()
+	conn := startServer(t, newServer(t, tks))
+
+	newKey := func(record keystore.KeyRecord) (string, ed25519.PrivateKey) {
+		publicKey, privateKey, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		record.ID = signing.Fingerprint(signing.Ed25519, publicKey)
+		record.PublicKey = publicKey
+		if err := tks.StoreKeyRecord(record); err != nil {
+			t.Fatal(err)
+		}
+		return record.ID, privateKey
+	}
+
+	expiredID, expiredKey := newKey(keystore.KeyRecord{NotAfter: time.Now().Add(-time.Minute)})
+	revokedID, revokedKey := newKey(keystore.KeyRecord{Revoked: true})
+	unknownID, unknownKey := newKey(keystore.KeyRecord{})
+	if err := tks.DeletePublicKey(unknownID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		keyID      string
+		privateKey ed25519.PrivateKey
+		reason     string
+	}{
+		{expiredID, expiredKey, auth.ReasonExpiredKey},
+		{revokedID, revokedKey, auth.ReasonRevokedKey},
+		{unknownID, unknownKey, auth.ReasonUnknownKey},
+	} {
+		req := &pb.EchoRequest{Message: "hi"}
+		ctx, err := auth.AppendSignature(context.Background(), tc.privateKey, tc.keyID, "/services.Echo/Echo", req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = pb.NewEchoClient(conn).Echo(ctx, req)
+		requireReason(t, err, tc.reason)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := signing.Fingerprint(signing.Ed25519, publicKey)
+
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(keyID, publicKey)
+
+	verifier, err := auth.NewVerifier(tks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interceptor := verifier.StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/services.Echo/Stream", IsServerStream: true}
+
+	call := func(md metadata.MD) (string, error) {
+		var authenticated string
+		err := interceptor(nil, &testServerStream{ctx: metadata.NewIncomingContext(context.Background(), md)}, info,
+			func(srv interface{}, stream grpc.ServerStream) error {
+				authenticated, _ = auth.KeyIDFromContext(stream.Context())
+				return nil
+			})
+		return authenticated, err
+	}
+
+	// Streams are signed without a body.
+	md, err := auth.Sign(privateKey, keyID, info.FullMethod, nil, time.Now(), "stream-nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticated, err := call(md)
+	if err != nil {
+		t.Fatalf("signed stream was rejected: %v", err)
+	}
+	if authenticated != keyID {
+		t.Fatalf("expected the handler to see key %s, got %q", keyID, authenticated)
+	}
+
+	_, err = call(md)
+	requireReason(t, err, auth.ReasonReplayedNonce)
+
+	other, err := auth.Sign(privateKey, keyID, "/services.Echo/Other", nil, time.Now(), "other-nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = call(other)
+	requireReason(t, err, auth.ReasonInvalidSignature)
+}
+
+// testServerStream is a grpc.ServerStream that only carries a context.
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,47 @@
+package intgtest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"grpc-app-auth/auth"
+	"grpc-app-auth/authz"
+	"grpc-app-auth/keystore"
+	server "grpc-app-auth/server"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthorizationPolicy(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := signing.Fingerprint(signing.Ed25519, publicKey)
+
+	tks := keystore.NewMemoryKeyStore()
+	tks.StoreKeyRecord(keystore.KeyRecord{ID: keyID, PublicKey: publicKey, Groups: []string{"echoers"}})
+
+	policy, err := authz.ParsePolicy([]byte(`{"rules": [{"groups": ["echoers"], "methods": ["/services.Echo/*"]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newServer(t, tks, server.WithAuthorizer(policy))
+	startServer(t, s)
+
+	conn := dialWithSigner(t, s, auth.NewSigner(keyID, privateKey))
+
+	if _, err := pb.NewEchoClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hi"}); err != nil {
+		t.Fatalf("echo should be allowed: %v", err)
+	}
+
+	_, err = pb.NewAddClient(conn).Add(context.Background(), &pb.AddRequest{A: 1, B: 2})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package intgtest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"grpc-app-auth/auth"
+	"grpc-app-auth/delegation"
+	"grpc-app-auth/keystore"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestDelegatedKey(t *testing.T) {
+	rootPublicKey, rootPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootKeyID := signing.Fingerprint(signing.Ed25519, rootPublicKey)
+
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(rootKeyID, rootPublicKey)
+
+	s := newServer(t, tks)
+	plain := startServer(t, s)
+
+	// The worker key is not in the key store; the root vouches for it.
+	_, workerPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, _, err := delegation.Issue(rootPrivateKey, workerPrivateKey.Public(), []string{"/services.Echo/*"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := auth.NewDelegatedSigner(workerPrivateKey, certificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := dialWithSigner(t, s, signer)
+
+	if _, err := pb.NewEchoClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hi"}); err != nil {
+		t.Fatalf("delegated key was rejected: %v", err)
+	}
+
+	_, err = pb.NewAddClient(conn).Add(context.Background(), &pb.AddRequest{A: 1, B: 2})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied outside the delegated methods, got %v", err)
+	}
+
+	// The certificate does not let another key sign.
+	_, otherPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKeyID := signing.Fingerprint(signing.Ed25519, otherPrivateKey.Public().(ed25519.PublicKey))
+	md, err := auth.Sign(otherPrivateKey, otherKeyID, "/services.Echo/Echo", &pb.EchoRequest{Message: "hi"}, time.Now(), "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	md.Set(auth.DelegationMetadataKey, certificate)
+	_, err = pb.NewEchoClient(plain).Echo(metadata.NewOutgoingContext(context.Background(), md), &pb.EchoRequest{Message: "hi"})
+	requireReason(t, err, auth.ReasonInvalidDelegation)
+
+	// Revoking the root revokes everything it delegated.
+	if err := tks.RevokePublicKey(rootKeyID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = pb.NewEchoClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+	requireReason(t, err, auth.ReasonRevokedKey)
+}
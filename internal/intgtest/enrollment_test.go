@@ -0,0 +1,209 @@
+package intgtest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"grpc-app-auth/auth"
+	"grpc-app-auth/canonical"
+	client "grpc-app-auth/client"
+	"grpc-app-auth/keystore"
+	server "grpc-app-auth/server"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestEnrollmentRequiresApproval(t *testing.T) {
+	adminPublicKey, adminPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminKeyID := signing.Fingerprint(signing.Ed25519, adminPublicKey)
+	adminKeys := keystore.NewMemoryKeyStore()
+	adminKeys.StorePublicKey(adminKeyID, adminPublicKey)
+
+	tks := keystore.NewMemoryKeyStore()
+	s := newServer(t, tks,
+		server.WithAdminKeys(adminKeys),
+		server.WithEnrollment(nil),
+	)
+	conn := startServer(t, s)
+	enrollment := pb.NewEnrollmentClient(conn)
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := enrollment.GetChallenge(context.Background(), &pb.ChallengeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A signature from a different key does not prove possession.
+	_, otherPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = enrollment.Enroll(context.Background(), &pb.EnrollRequest{
+		PublicKey: publicKey,
+		Challenge: ch.Challenge,
+		Signature: ed25519.Sign(otherPrivateKey, canonical.EncodeEnrollment(ch.Challenge, publicKey)),
+	})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+
+	// The challenge was consumed by the failed attempt.
+	enroll := &pb.EnrollRequest{
+		PublicKey: publicKey,
+		Label:     "new worker",
+		Challenge: ch.Challenge,
+		Signature: ed25519.Sign(privateKey, canonical.EncodeEnrollment(ch.Challenge, publicKey)),
+	}
+	_, err = enrollment.Enroll(context.Background(), enroll)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected reused challenge to be rejected, got %v", err)
+	}
+
+	ch, err = enrollment.GetChallenge(context.Background(), &pb.ChallengeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enroll.Challenge = ch.Challenge
+	enroll.Signature = ed25519.Sign(privateKey, canonical.EncodeEnrollment(ch.Challenge, publicKey))
+	reply, err := enrollment.Enroll(context.Background(), enroll)
+	if err != nil {
+		t.Fatalf("enroll failed: %v", err)
+	}
+	if reply.Approved {
+		t.Fatal("expected enrollment to wait for approval")
+	}
+	if record, _ := tks.GetKeyRecord(reply.KeyId); record.Label != "" || record.RequestedLabel != "new worker" {
+		t.Fatalf("expected the label to wait for approval, got %+v", record)
+	}
+
+	echo := pb.NewEchoClient(dialWithSigner(t, s, auth.NewSigner(reply.KeyId, privateKey)))
+	_, err = echo.Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+	requireReason(t, err, auth.ReasonPendingKey)
+
+	admin := pb.NewKeyAdminClient(dialWithSigner(t, s, auth.NewSigner(adminKeyID, adminPrivateKey)))
+	if _, err := admin.ApproveKey(context.Background(), &pb.ApproveKeyRequest{KeyId: reply.KeyId}); err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+
+	if _, err := echo.Echo(context.Background(), &pb.EchoRequest{Message: "hi"}); err != nil {
+		t.Fatalf("approved key was rejected: %v", err)
+	}
+	if record, _ := tks.GetKeyRecord(reply.KeyId); record.Label != "new worker" || record.RequestedLabel != "" {
+		t.Fatalf("expected approval to grant the requested label, got %+v", record)
+	}
+}
+
+func TestEnrollmentTokenApproves(t *testing.T) {
+	tks := keystore.NewMemoryKeyStore()
+	s := newServer(t, tks, server.WithEnrollment(server.EnrollmentTokenPolicy("secret")))
+	startServer(t, s)
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	approved, err := dialClient(t, s, client.WithSigner(privateKey)).Enroll(context.Background(), "worker", "secret")
+	if err != nil {
+		t.Fatalf("enroll failed: %v", err)
+	}
+	if !approved {
+		t.Fatal("expected the enrollment token to approve the key")
+	}
+
+	record, err := tks.GetKeyRecord(signing.Fingerprint(signing.Ed25519, publicKey))
+	if err != nil {
+		t.Fatalf("expected enrolled key to be trusted: %v", err)
+	}
+	// The token vouches for the key, not for the label the client chose.
+	if record.Label != "" || record.RequestedLabel != "worker" {
+		t.Fatalf("expected the label to wait for an admin, got %+v", record)
+	}
+}
+
+func TestEnrollmentCapsPendingKeys(t *testing.T) {
+	tks := keystore.NewMemoryKeyStore()
+	for i := 0; i < server.MaxPendingEnrollments; i++ {
+		publicKey, _, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tks.StoreKeyRecord(keystore.KeyRecord{ID: signing.Fingerprint(signing.Ed25519, publicKey), PublicKey: publicKey, Pending: true})
+	}
+
+	s := newServer(t, tks, server.WithEnrollment(server.EnrollmentTokenPolicy("secret")))
+	startServer(t, s)
+
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dialClient(t, s, client.WithSigner(privateKey))
+	if _, err := c.Enroll(context.Background(), "worker", "wrong"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+
+	// Keys the policy approves do not wait, so they are still accepted.
+	if approved, err := c.Enroll(context.Background(), "worker", "secret"); err != nil || !approved {
+		t.Fatalf("expected the enrollment token to approve the key, got %v, %v", approved, err)
+	}
+}
+
+func TestChallengesAreScopedToTheirService(t *testing.T) {
+	s := newServer(t, keystore.NewMemoryKeyStore(),
+		server.WithEnrollment(server.EnrollmentTokenPolicy("secret")),
+		server.WithSessions(nil, time.Minute),
+	)
+	conn := startServer(t, s)
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each service issues challenges from its own store, so a login
+	// challenge cannot be redeemed by Enroll.
+	ch, err := pb.NewSessionClient(conn).GetLoginChallenge(context.Background(), &pb.ChallengeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pb.NewEnrollmentClient(conn).Enroll(context.Background(), &pb.EnrollRequest{
+		PublicKey:       publicKey,
+		Challenge:       ch.Challenge,
+		Signature:       ed25519.Sign(privateKey, canonical.EncodeEnrollment(ch.Challenge, publicKey)),
+		EnrollmentToken: "secret",
+	})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestEnrollmentDisabledByDefault(t *testing.T) {
+	s := newServer(t, keystore.NewMemoryKeyStore())
+	startServer(t, s)
+
+	conn, err := grpc.Dial(s.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	_, err = pb.NewEnrollmentClient(conn).GetChallenge(context.Background(), &pb.ChallengeRequest{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected Unimplemented, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package intgtest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"grpc-app-auth/auth"
+	"grpc-app-auth/keystore"
+	server "grpc-app-auth/server"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestKeyAdmin(t *testing.T) {
+	adminPublicKey, adminPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminKeyID := signing.Fingerprint(signing.Ed25519, adminPublicKey)
+
+	adminKeys := keystore.NewMemoryKeyStore()
+	adminKeys.StorePublicKey(adminKeyID, adminPublicKey)
+
+	s := newServer(t, keystore.NewMemoryKeyStore(), server.WithAdminKeys(adminKeys))
+	startServer(t, s)
+
+	admin := pb.NewKeyAdminClient(dialWithSigner(t, s, auth.NewSigner(adminKeyID, adminPrivateKey)))
+
+	clientPublicKey, clientPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := admin.RegisterKey(context.Background(), &pb.RegisterKeyRequest{PublicKey: clientPublicKey, Label: "worker"})
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+
+	_, err = admin.RegisterKey(context.Background(), &pb.RegisterKeyRequest{PublicKey: clientPublicKey})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("expected AlreadyExists, got %v", err)
+	}
+
+	echo := pb.NewEchoClient(dialWithSigner(t, s, auth.NewSigner(info.KeyId, clientPrivateKey)))
+	if _, err := echo.Echo(context.Background(), &pb.EchoRequest{Message: "hi"}); err != nil {
+		t.Fatalf("registered key was rejected: %v", err)
+	}
+
+	// Client keys must not be able to administer the server.
+	clientAdmin := pb.NewKeyAdminClient(dialWithSigner(t, s, auth.NewSigner(info.KeyId, clientPrivateKey)))
+	_, err = clientAdmin.ListKeys(context.Background(), &pb.ListKeysRequest{})
+	requireReason(t, err, auth.ReasonUnknownKey)
+
+	list, err := admin.ListKeys(context.Background(), &pb.ListKeysRequest{})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(list.Keys) != 1 || list.Keys[0].Label != "worker" {
+		t.Fatalf("unexpected keys %v", list.Keys)
+	}
+
+	revoked, err := admin.RevokeKey(context.Background(), &pb.RevokeKeyRequest{KeyId: info.KeyId})
+	if err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+	if !revoked.Revoked {
+		t.Fatal("expected key to be reported as revoked")
+	}
+
+	_, err = echo.Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+	requireReason(t, err, auth.ReasonRevokedKey)
+
+	_, err = admin.GetKey(context.Background(), &pb.GetKeyRequest{KeyId: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
+
+func TestKeyAdminRejectsInvalidRecords(t *testing.T) {
+	adminPublicKey, adminPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminKeyID := signing.Fingerprint(signing.Ed25519, adminPublicKey)
+	adminKeys := keystore.NewMemoryKeyStore()
+	adminKeys.StorePublicKey(adminKeyID, adminPublicKey)
+
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	tks, err := keystore.NewFileKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tks.Close() })
+
+	s := newServer(t, tks, server.WithAdminKeys(adminKeys))
+	startServer(t, s)
+	admin := pb.NewKeyAdminClient(dialWithSigner(t, s, auth.NewSigner(adminKeyID, adminPrivateKey)))
+
+	clientPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file cannot hold a group name containing its option separator.
+	_, err = admin.RegisterKey(context.Background(), &pb.RegisterKeyRequest{PublicKey: clientPublicKey, Groups: []string{"ops,admins"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func dialWithSigner(t *testing.T, s *server.Server, signer *auth.Signer) *grpc.ClientConn {
+	t.Helper()
+	return dialTarget(t, s.Addr().String(), signer)
+}
@@ -0,0 +1,117 @@
+package intgtest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"grpc-app-auth/auth"
+	"grpc-app-auth/keystore"
+	server "grpc-app-auth/server"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+)
+
+func TestStopBeforeServe(t *testing.T) {
+	s := server.NewServerWithTrustedKeys(keystore.NewMemoryKeyStore())
+	s.Stop()
+	s.Stop()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ServeListener(lis); !errors.Is(err, server.ErrServerStopped) {
+		t.Fatalf("expected ErrServerStopped, got %v", err)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// blockingAuthorizer holds every call until released, so tests can shut the
+// server down with a request in flight.
+type blockingAuthorizer struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (a *blockingAuthorizer) Authorize(ctx context.Context, id auth.Identity, fullMethod string) error {
+	a.entered <- struct{}{}
+	select {
+	case <-a.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestRunDrainsInFlightRequests(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		drainTimeout time.Duration
+		release      bool
+	}{
+		{name: "finishes", drainTimeout: 5 * time.Second, release: true},
+		{name: "deadline", drainTimeout: 100 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			publicKey, privateKey, err := ed25519.GenerateKey(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keyID := signing.Fingerprint(signing.Ed25519, publicKey)
+			tks := keystore.NewMemoryKeyStore()
+			tks.StorePublicKey(keyID, publicKey)
+
+			authorizer := &blockingAuthorizer{entered: make(chan struct{}, 1), release: make(chan struct{})}
+			s, err := server.NewServerWithTrustedKeysAndFuncOpts(tks,
+				server.WithAddress("127.0.0.1:0"),
+				server.WithAuthorizer(authorizer),
+				server.WithDrainTimeout(tc.drainTimeout),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan error, 1)
+			go func() { done <- s.Run(ctx) }()
+			<-s.Ready()
+
+			echo := pb.NewEchoClient(dialTarget(t, s.Addr().String(), auth.NewSigner(keyID, privateKey)))
+			result := make(chan error, 1)
+			go func() {
+				_, err := echo.Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+				result <- err
+			}()
+			<-authorizer.entered
+
+			start := time.Now()
+			cancel()
+			if tc.release {
+				time.Sleep(50 * time.Millisecond)
+				close(authorizer.release)
+			}
+
+			if err := <-done; err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Fatalf("shutdown took %v", elapsed)
+			}
+
+			err = <-result
+			if tc.release && err != nil {
+				t.Fatalf("in-flight request was not drained: %v", err)
+			}
+			if !tc.release && err == nil {
+				t.Fatal("expected the in-flight request to be closed at the drain deadline")
+			}
+		})
+	}
+}
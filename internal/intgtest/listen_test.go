@@ -0,0 +1,191 @@
+package intgtest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"grpc-app-auth/auth"
+	"grpc-app-auth/keystore"
+	server "grpc-app-auth/server"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestServeListener(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := signing.Fingerprint(signing.Ed25519, publicKey)
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(keyID, publicKey)
+	signer := auth.NewSigner(keyID, privateKey)
+
+	// Two servers run side by side on ports picked by the kernel.
+	for i := 0; i < 2; i++ {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := server.NewServerWithTrustedKeys(tks)
+		errs := make(chan error, 1)
+		go func() { errs <- s.ServeListener(lis) }()
+		t.Cleanup(func() {
+			s.Stop()
+			if err := <-errs; err != nil {
+				t.Errorf("serve failed: %v", err)
+			}
+		})
+
+		echo := pb.NewEchoClient(dialTarget(t, lis.Addr().String(), signer))
+		if _, err := echo.Echo(context.Background(), &pb.EchoRequest{Message: "hi"}); err != nil {
+			t.Fatalf("server %d rejected request: %v", i, err)
+		}
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := signing.Fingerprint(signing.Ed25519, publicKey)
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(keyID, publicKey)
+
+	// A socket left behind by an earlier server is replaced.
+	path := filepath.Join(t.TempDir(), "server.sock")
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	s, err := server.NewServerWithTrustedKeysAndFuncOpts(tks, server.WithUnixSocket(path, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error, 1)
+	go func() { errs <- s.Serve() }()
+	t.Cleanup(func() {
+		s.Stop()
+		if err := <-errs; err != nil {
+			t.Errorf("serve failed: %v", err)
+		}
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("expected socket to be removed on stop, got %v", err)
+		}
+	})
+
+	echo := pb.NewEchoClient(dialTarget(t, "unix://"+path, auth.NewSigner(keyID, privateKey)))
+	if _, err := echo.Echo(context.Background(), &pb.EchoRequest{Message: "hi"}, grpc.WaitForReady(true)); err != nil {
+		t.Fatalf("request over unix socket failed: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != server.DefaultSocketMode {
+		t.Fatalf("unexpected socket permissions %v", info.Mode().Perm())
+	}
+	// The directory the socket was created in is gone.
+	if entries, err := os.ReadDir(filepath.Dir(path)); err != nil || len(entries) != 1 {
+		t.Fatalf("unexpected files next to the socket: %v, %v", entries, err)
+	}
+}
+
+func TestUnixSocketLeavesOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.sock")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := server.NewServerWithTrustedKeysAndFuncOpts(keystore.NewMemoryKeyStore(), server.WithUnixSocket(path, 0600))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Serve(); err == nil {
+		t.Fatal("expected a regular file at the socket path to be left alone")
+	}
+	if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+		t.Fatalf("expected the file to be kept, got %v, %v", info, err)
+	}
+}
+
+func TestUnixSocketLeavesLiveSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.sock")
+	live, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+
+	s, err := server.NewServerWithTrustedKeysAndFuncOpts(keystore.NewMemoryKeyStore(), server.WithUnixSocket(path, 0600))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Serve(); err == nil {
+		t.Fatal("expected a socket another server accepts on to be left alone")
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("expected the live socket to be kept, got %v", err)
+	}
+	conn.Close()
+}
+
+func TestUnixSocketPathTooLong(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), strings.Repeat("d", 100))
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := server.NewServerWithTrustedKeysAndFuncOpts(keystore.NewMemoryKeyStore(), server.WithUnixSocket(filepath.Join(dir, "server.sock"), 0600))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Serve(); err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Fatalf("expected the socket path to be rejected as too long, got %v", err)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Fatalf("unexpected files left behind: %v, %v", entries, err)
+	}
+}
+
+func TestServeReturnsListenError(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	s, err := server.NewServerWithTrustedKeysAndFuncOpts(keystore.NewMemoryKeyStore(), server.WithAddress(lis.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Serve(); err == nil {
+		t.Fatal("expected an error listening on an address in use")
+	}
+}
+
+func dialTarget(t *testing.T, target string, signer *auth.Signer) *grpc.ClientConn {
+	t.Helper()
+	opts := append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, signer.DialOptions()...)
+	conn, err := grpc.Dial(target, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
@@ -0,0 +1,108 @@
+package intgtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-app-auth/auth"
+	client "grpc-app-auth/client"
+	"grpc-app-auth/keystore"
+	"grpc-app-auth/multisig"
+	server "grpc-app-auth/server"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestServerLogsRedactCredentials(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := signing.Fingerprint(signing.Ed25519, publicKey)
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(keyID, publicKey)
+
+	s := newServer(t, tks,
+		server.WithAdminKeys(tks),
+		server.WithSessions(nil, time.Minute),
+		server.WithEnrollment(server.EnrollmentTokenPolicy("enrollment-secret")),
+		server.WithQuorum("/services.KeyAdmin/ListKeys", 1, ""),
+	)
+	conn := startServer(t, s)
+
+	// A signed request.
+	ctx, err := auth.AppendSignature(context.Background(), privateKey, keyID, "/services.Echo/Echo", &pb.EchoRequest{Message: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pb.NewEchoClient(conn).Echo(ctx, &pb.EchoRequest{Message: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	secrets := md[auth.SignatureMetadataKey]
+
+	// A session login and a request carrying its token.
+	c := dialClient(t, s, client.WithSigner(privateKey))
+	if _, err := c.Login(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Echo(context.Background(), "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	// An enrollment with a token.
+	_, otherPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dialClient(t, s, client.WithSigner(otherPrivateKey)).Enroll(context.Background(), "worker", "enrollment-secret"); err != nil {
+		t.Fatal(err)
+	}
+	secrets = append(secrets, "enrollment-secret")
+
+	// A multi-signature envelope.
+	req := &pb.ListKeysRequest{}
+	envelope, err := multisig.NewEnvelope("/services.KeyAdmin/ListKeys", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := envelope.Sign(keyID, privateKey); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err = envelope.AppendToOutgoingContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pb.NewKeyAdminClient(conn).ListKeys(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	md, _ = metadata.FromOutgoingContext(ctx)
+	secrets = append(secrets, md[multisig.MetadataKey]...)
+	secrets = append(secrets, base64.StdEncoding.EncodeToString(envelope.Signatures[0].Signature))
+
+	out := logs.String()
+	if !strings.Contains(out, "[REDACTED]") {
+		t.Fatal("expected credentials to be redacted in the log")
+	}
+	for _, secret := range secrets {
+		if strings.Contains(out, secret) {
+			t.Errorf("log contains %q", secret)
+		}
+	}
+	if strings.Contains(out, "Bearer ") || strings.Contains(out, "token:") {
+		t.Error("log contains a session token")
+	}
+}
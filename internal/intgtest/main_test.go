@@ -1,12 +1,15 @@
 package intgtest
 
 import (
+	"context"
 	"crypto/ed25519"
-	"encoding/base64"
+	"grpc-app-auth/auth"
 	client "grpc-app-auth/client"
-	"grpc-app-auth/internal"
+	"grpc-app-auth/keystore"
 	server "grpc-app-auth/server"
+	"grpc-app-auth/signing"
 	"testing"
+	"time"
 )
 
 func Test(t *testing.T) {
@@ -15,13 +18,73 @@ func Test(t *testing.T) {
 		panic(err)
 	}
 
-	tks := &internal.TrustedKeyStore{Keys: map[string]ed25519.PublicKey{}}
-	tks.StorePublicKey(base64.StdEncoding.EncodeToString(publicKey), publicKey)
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(signing.Fingerprint(signing.Ed25519, publicKey), publicKey)
 
-	s := server.NewServerWithTrustedKeys(tks)
-	c := client.NewClientWithKeys(publicKey, privateKey)
+	s := newServer(t, tks, server.WithSessions(nil, time.Minute))
+	startServer(t, s)
 
-	go s.Serve()
-	t.Cleanup(s.Stop)
-	c.Echo("Hello World")
+	c := dialClient(t, s, client.WithSigner(privateKey))
+	greeting, err := c.Echo(context.Background(), "Hello World")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if greeting != "Echo Hello World" {
+		t.Fatalf("unexpected greeting %q", greeting)
+	}
+
+	// After logging in the same connection carries the session token.
+	if _, err := c.Login(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := c.Add(context.Background(), 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != 3 {
+		t.Fatalf("unexpected sum %v", sum)
+	}
+
+	// Errors are returned rather than ending the process.
+	_, otherPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dialClient(t, s, client.WithSigner(otherPrivateKey)).Echo(context.Background(), "Hello World")
+	requireReason(t, err, auth.ReasonUnknownKey)
+}
+
+func dialClient(t *testing.T, s *server.Server, opts ...client.ClientOption) *client.Client {
+	t.Helper()
+	c, err := client.Dial(s.Addr().String(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClientSignsOnceSessionExpires(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(signing.Fingerprint(signing.Ed25519, publicKey), publicKey)
+
+	s := newServer(t, tks, server.WithSessions(nil, time.Second))
+	startServer(t, s)
+
+	c := dialClient(t, s, client.WithSigner(privateKey))
+	expiry, err := c.Login(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Until(expiry))
+
+	// The request is still there to sign when the client falls back to its key.
+	if _, err := c.Add(context.Background(), 1, 2); err != nil {
+		t.Fatalf("expected the client to sign once its session expired: %v", err)
+	}
 }
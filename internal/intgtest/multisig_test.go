@@ -0,0 +1,127 @@
+package intgtest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"strconv"
+	"testing"
+
+	"grpc-app-auth/auth"
+	"grpc-app-auth/keystore"
+	"grpc-app-auth/multisig"
+	server "grpc-app-auth/server"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestMultiSignatureQuorum(t *testing.T) {
+	adminKeys := keystore.NewMemoryKeyStore()
+	var admins []ed25519.PrivateKey
+	var adminKeyIDs []string
+	for _, groups := range [][]string{{"admins"}, {"admins"}, {"admins"}, nil} {
+		publicKey, privateKey, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyID := signing.Fingerprint(signing.Ed25519, publicKey)
+		if err := adminKeys.StoreKeyRecord(keystore.KeyRecord{ID: keyID, PublicKey: publicKey, Groups: groups}); err != nil {
+			t.Fatal(err)
+		}
+		admins = append(admins, privateKey)
+		adminKeyIDs = append(adminKeyIDs, keyID)
+	}
+	outsider := 3
+
+	s := newServer(t, keystore.NewMemoryKeyStore(),
+		server.WithAdminKeys(adminKeys),
+		server.WithQuorum("/services.KeyAdmin/RevokeKey", 2, "admins"),
+	)
+	plain := startServer(t, s)
+
+	// Methods without a quorum still take a single signature.
+	admin := pb.NewKeyAdminClient(dialWithSigner(t, s, auth.NewSigner(adminKeyIDs[0], admins[0])))
+	clientPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := admin.RegisterKey(context.Background(), &pb.RegisterKeyRequest{PublicKey: clientPublicKey})
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+
+	req := &pb.RevokeKeyRequest{KeyId: info.KeyId}
+	_, err = admin.RevokeKey(context.Background(), req)
+	requireReason(t, err, auth.ReasonQuorumNotMet)
+
+	revoke := func(envelope *multisig.Envelope, req *pb.RevokeKeyRequest) error {
+		ctx, err := envelope.AppendToOutgoingContext(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = pb.NewKeyAdminClient(plain).RevokeKey(ctx, req)
+		return err
+	}
+
+	envelope, err := multisig.NewEnvelope("/services.KeyAdmin/RevokeKey", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := envelope.Sign(adminKeyIDs[0], admins[0]); err != nil {
+		t.Fatal(err)
+	}
+	requireReason(t, revoke(envelope, req), auth.ReasonQuorumNotMet)
+
+	// Signing twice with one key, or with a key outside the group, does not
+	// count toward the quorum.
+	if err := envelope.Sign(adminKeyIDs[0], admins[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := envelope.Sign(adminKeyIDs[outsider], admins[outsider]); err != nil {
+		t.Fatal(err)
+	}
+	requireReason(t, revoke(envelope, req), auth.ReasonQuorumNotMet)
+
+	// The envelope travels to a second admin, who checks the request before
+	// co-signing it.
+	encoded, err := envelope.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, err := multisig.Parse(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pending pb.RevokeKeyRequest
+	if err := received.Unmarshal(&pending); err != nil {
+		t.Fatal(err)
+	}
+	if pending.KeyId != info.KeyId {
+		t.Fatalf("unexpected request to co-sign %v", &pending)
+	}
+	if err := received.Sign(adminKeyIDs[1], admins[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	// The signatures only cover the request they were collected for.
+	requireReason(t, revoke(received, &pb.RevokeKeyRequest{KeyId: adminKeyIDs[2]}), auth.ReasonInvalidSignature)
+
+	// A co-signer cannot lift another admin's signature out of the envelope
+	// and send it as a single-key request, here to a replica with no quorum.
+	replica := startServer(t, newServer(t, keystore.NewMemoryKeyStore(), server.WithAdminKeys(adminKeys)))
+	lifted := metadata.AppendToOutgoingContext(context.Background(),
+		auth.KeyMetadataKey, received.Signatures[0].KeyID,
+		auth.SignatureMetadataKey, base64.StdEncoding.EncodeToString(received.Signatures[0].Signature),
+		auth.TimestampMetadataKey, strconv.FormatInt(received.Timestamp, 10),
+		auth.NonceMetadataKey, received.Nonce,
+	)
+	_, err = pb.NewKeyAdminClient(replica).RevokeKey(lifted, req)
+	requireReason(t, err, auth.ReasonInvalidSignature)
+
+	if err := revoke(received, req); err != nil {
+		t.Fatalf("quorum was rejected: %v", err)
+	}
+	requireReason(t, revoke(received, req), auth.ReasonReplayedNonce)
+}
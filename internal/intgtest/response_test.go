@@ -0,0 +1,111 @@
+package intgtest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"grpc-app-auth/auth"
+	client "grpc-app-auth/client"
+	"grpc-app-auth/keystore"
+	server "grpc-app-auth/server"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestSignedResponses(t *testing.T) {
+	clientPublicKey, clientPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientKeyID := signing.Fingerprint(signing.Ed25519, clientPublicKey)
+	serverPublicKey, serverPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(clientKeyID, clientPublicKey)
+
+	s := newServer(t, tks, server.WithIdentity(serverPrivateKey))
+	if s.IdentityKeyID() != signing.Fingerprint(signing.Ed25519, serverPublicKey) {
+		t.Fatalf("unexpected identity %s", s.IdentityKeyID())
+	}
+	startServer(t, s)
+
+	signer := auth.NewSigner(clientKeyID, clientPrivateKey)
+	dial := func(serverKey ed25519.PublicKey, extra ...grpc.DialOption) pb.EchoClient {
+		opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+		opts = append(opts, signer.DialOptions()...)
+		opts = append(opts, auth.NewResponseVerifier(serverKey).DialOptions()...)
+		conn, err := grpc.Dial(s.Addr().String(), append(opts, extra...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return pb.NewEchoClient(conn)
+	}
+
+	r, err := dial(serverPublicKey).Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+	if err != nil {
+		t.Fatalf("signed response was rejected: %v", err)
+	}
+	if r.Message != "Echo hi" {
+		t.Fatalf("unexpected reply %q", r.Message)
+	}
+
+	// A response altered after the server signed it fails the call.
+	tamper := grpc.WithChainUnaryInterceptor(func(
+		ctx context.Context, method string, req, reply interface{},
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+	) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		reply.(*pb.EchoReply).Message = "Echo something else"
+		return err
+	})
+	_, err = dial(serverPublicKey, tamper).Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+	requireReason(t, err, auth.ReasonInvalidServerSignature)
+
+	otherPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dial(otherPublicKey).Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+	requireReason(t, err, auth.ReasonInvalidServerSignature)
+
+	c := dialClient(t, s, client.WithSigner(clientPrivateKey), client.WithServerKey(serverPublicKey))
+	if _, err := c.Echo(context.Background(), "Hello World"); err != nil {
+		t.Fatalf("client rejected a signed response: %v", err)
+	}
+}
+
+func TestUnsignedResponseRejected(t *testing.T) {
+	clientPublicKey, clientPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientKeyID := signing.Fingerprint(signing.Ed25519, clientPublicKey)
+	serverPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(clientKeyID, clientPublicKey)
+	s := newServer(t, tks)
+	startServer(t, s)
+
+	opts := append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		auth.NewSigner(clientKeyID, clientPrivateKey).DialOptions()...)
+	conn, err := grpc.Dial(s.Addr().String(), append(opts, auth.NewResponseVerifier(serverPublicKey).DialOptions()...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = pb.NewEchoClient(conn).Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+	requireReason(t, err, auth.ReasonInvalidServerSignature)
+}
@@ -0,0 +1,184 @@
+package intgtest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"grpc-app-auth/auth"
+	"grpc-app-auth/canonical"
+	client "grpc-app-auth/client"
+	"grpc-app-auth/keystore"
+	server "grpc-app-auth/server"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestKeyRotation(t *testing.T) {
+	oldPublicKey, oldPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKeyID := signing.Fingerprint(signing.Ed25519, oldPublicKey)
+
+	tks := keystore.NewMemoryKeyStore()
+	tks.StoreKeyRecord(keystore.KeyRecord{ID: oldKeyID, PublicKey: oldPublicKey, Label: "worker", Groups: []string{"workers"}})
+
+	s := newServer(t, tks, server.WithKeyRotation(time.Hour))
+	startServer(t, s)
+
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dialClient(t, s, client.WithSigner(oldPrivateKey))
+	if err := c.SetNextKey(newKey); err != nil {
+		t.Fatal(err)
+	}
+	newKeyID := c.NextKeyID()
+
+	if _, err := c.RotateKey(context.Background()); err != nil {
+		t.Fatalf("rotation failed: %v", err)
+	}
+	if c.KeyID() != newKeyID || c.NextKeyID() != "" {
+		t.Fatalf("expected client to sign with %s, got %s", newKeyID, c.KeyID())
+	}
+	if _, err := c.Echo(context.Background(), "hi"); err != nil {
+		t.Fatalf("client was rejected after rotating: %v", err)
+	}
+
+	record, err := tks.GetKeyRecord(newKeyID)
+	if err != nil {
+		t.Fatalf("expected new key to be trusted: %v", err)
+	}
+	if record.Algorithm != signing.ECDSAP256SHA256 || record.Label != "worker" || len(record.Groups) != 1 {
+		t.Fatalf("unexpected new record %+v", record)
+	}
+
+	// The old key keeps working for the grace period.
+	old, err := tks.GetKeyRecord(oldKeyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining := time.Until(old.NotAfter); remaining <= 0 || remaining > time.Hour {
+		t.Fatalf("expected old key to expire within the grace period, got %v", old.NotAfter)
+	}
+	echo := pb.NewEchoClient(dialWithSigner(t, s, auth.NewSigner(oldKeyID, oldPrivateKey)))
+	if _, err := echo.Echo(context.Background(), &pb.EchoRequest{Message: "hi"}); err != nil {
+		t.Fatalf("old key was rejected during the grace period: %v", err)
+	}
+	echo = pb.NewEchoClient(dialWithSigner(t, s, auth.NewSigner(newKeyID, newKey)))
+	if _, err := echo.Echo(context.Background(), &pb.EchoRequest{Message: "hi"}); err != nil {
+		t.Fatalf("new key was rejected: %v", err)
+	}
+}
+
+func TestKeyRotationKeepsValidity(t *testing.T) {
+	oldPublicKey, oldPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKeyID := signing.Fingerprint(signing.Ed25519, oldPublicKey)
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	tks := keystore.NewMemoryKeyStore()
+	tks.StoreKeyRecord(keystore.KeyRecord{ID: oldKeyID, PublicKey: oldPublicKey, NotAfter: notAfter})
+
+	s := newServer(t, tks, server.WithKeyRotation(24*time.Hour))
+	startServer(t, s)
+
+	_, newKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dialClient(t, s, client.WithSigner(oldPrivateKey))
+	if err := c.SetNextKey(newKey); err != nil {
+		t.Fatal(err)
+	}
+	newKeyID := c.NextKeyID()
+	if _, err := c.RotateKey(context.Background()); err != nil {
+		t.Fatalf("rotation failed: %v", err)
+	}
+
+	// Rotating cannot turn a key that expires in an hour into one that
+	// never does.
+	record, err := tks.GetKeyRecord(newKeyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !record.NotAfter.Equal(notAfter) {
+		t.Fatalf("expected new key to expire at %v, got %v", notAfter, record.NotAfter)
+	}
+	old, err := tks.GetKeyRecord(oldKeyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !old.NotAfter.Equal(notAfter) {
+		t.Fatalf("expected old key to keep expiring at %v, got %v", notAfter, old.NotAfter)
+	}
+}
+
+func TestKeyRotationRequiresBothSignatures(t *testing.T) {
+	oldPublicKey, oldPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKeyID := signing.Fingerprint(signing.Ed25519, oldPublicKey)
+	newPublicKey, newPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(oldKeyID, oldPublicKey)
+
+	s := newServer(t, tks, server.WithKeyRotation(time.Millisecond))
+	conn := startServer(t, s)
+	rotation := pb.NewKeyRotationClient(conn)
+
+	rotate := func(endorsedBy ed25519.PrivateKey, provedBy ed25519.PrivateKey) error {
+		ch, err := rotation.GetRotationChallenge(context.Background(), &pb.ChallengeRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		statement := canonical.EncodeRotation(ch.Challenge, oldKeyID, "", newPublicKey)
+		_, err = rotation.RotateKey(context.Background(), &pb.RotateKeyRequest{
+			KeyId:        oldKeyID,
+			NewPublicKey: newPublicKey,
+			Challenge:    ch.Challenge,
+			Endorsement:  ed25519.Sign(endorsedBy, statement),
+			Proof:        ed25519.Sign(provedBy, statement),
+		})
+		return err
+	}
+
+	// The new key alone cannot take over the old key's identity.
+	if err := rotate(newPrivateKey, newPrivateKey); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected a missing endorsement to be rejected, got %v", err)
+	}
+	// Nor can the old key endorse a key it does not hold.
+	if err := rotate(oldPrivateKey, oldPrivateKey); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected a missing proof of possession to be rejected, got %v", err)
+	}
+
+	if err := rotate(oldPrivateKey, newPrivateKey); err != nil {
+		t.Fatalf("rotation failed: %v", err)
+	}
+
+	// Once the grace period is over the old key is expired and cannot be
+	// rotated again.
+	time.Sleep(10 * time.Millisecond)
+	echo := pb.NewEchoClient(dialWithSigner(t, s, auth.NewSigner(oldKeyID, oldPrivateKey)))
+	_, err = echo.Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+	requireReason(t, err, auth.ReasonExpiredKey)
+
+	err = rotate(oldPrivateKey, newPrivateKey)
+	requireReason(t, err, auth.ReasonExpiredKey)
+}
@@ -0,0 +1,108 @@
+package intgtest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"grpc-app-auth/auth"
+	"grpc-app-auth/canonical"
+	"grpc-app-auth/keystore"
+	server "grpc-app-auth/server"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestSessionLogin(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := signing.Fingerprint(signing.Ed25519, publicKey)
+
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(keyID, publicKey)
+	adminKeys := keystore.NewMemoryKeyStore()
+	adminKeys.StorePublicKey(keyID, publicKey)
+
+	s := newServer(t, tks,
+		server.WithSessions(nil, time.Minute),
+		server.WithAdminKeys(adminKeys),
+	)
+	conn := startServer(t, s)
+	sessions := pb.NewSessionClient(conn)
+
+	ch, err := sessions.GetLoginChallenge(context.Background(), &pb.ChallengeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	login, err := sessions.Login(context.Background(), &pb.LoginRequest{
+		KeyId:     keyID,
+		Challenge: ch.Challenge,
+		Signature: ed25519.Sign(privateKey, canonical.EncodeLogin(ch.Challenge, keyID)),
+		Scopes:    []string{"/services.Echo/*"},
+	})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	tokenConn, err := grpc.Dial(s.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithPerRPCCredentials(auth.NewSessionCredentials(login.Token)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tokenConn.Close() })
+
+	if _, err := pb.NewEchoClient(tokenConn).Echo(context.Background(), &pb.EchoRequest{Message: "hi"}); err != nil {
+		t.Fatalf("echo with session token failed: %v", err)
+	}
+
+	_, err = pb.NewAddClient(tokenConn).Add(context.Background(), &pb.AddRequest{A: 1, B: 2})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied outside token scope, got %v", err)
+	}
+
+	// Session tokens are never accepted by services with their own key store.
+	_, err = pb.NewKeyAdminClient(tokenConn).ListKeys(context.Background(), &pb.ListKeysRequest{})
+	requireReason(t, err, auth.ReasonInvalidSessionToken)
+
+	// A challenge signed for login by an unknown key is rejected.
+	_, otherPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, err = sessions.GetLoginChallenge(context.Background(), &pb.ChallengeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sessions.Login(context.Background(), &pb.LoginRequest{
+		KeyId:     keyID,
+		Challenge: ch.Challenge,
+		Signature: ed25519.Sign(otherPrivateKey, canonical.EncodeLogin(ch.Challenge, keyID)),
+	})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestSessionsRejectMalformedKey(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []ed25519.PrivateKey{{}, privateKey.Seed()} {
+		_, err := server.NewServerWithTrustedKeysAndFuncOpts(keystore.NewMemoryKeyStore(), server.WithSessions(key, time.Minute))
+		if err == nil {
+			t.Fatalf("expected a %d byte session key to be rejected", len(key))
+		}
+	}
+}
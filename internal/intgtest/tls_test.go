@@ -0,0 +1,208 @@
+package intgtest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"grpc-app-auth/auth"
+	client "grpc-app-auth/client"
+	"grpc-app-auth/keystore"
+	"grpc-app-auth/multisig"
+	server "grpc-app-auth/server"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+	"grpc-app-auth/tlsutil"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+func TestSelfSignedTLSWithChannelBinding(t *testing.T) {
+	clientPublicKey, clientPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientKeyID := signing.Fingerprint(signing.Ed25519, clientPublicKey)
+	serverPublicKey, serverPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(clientKeyID, clientPublicKey)
+
+	s := newServer(t, tks,
+		server.WithIdentity(serverPrivateKey),
+		server.WithSelfSignedTLS(serverPrivateKey, "127.0.0.1"),
+		server.WithChannelBinding(),
+	)
+
+	config, err := tlsutil.PinnedClientConfig(clientPrivateKey, serverPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(t, s)
+
+	signer := auth.NewSigner(clientKeyID, clientPrivateKey)
+	bound := pb.NewEchoClient(dialTLS(t, s, config, signer.WithChannelBinding().DialOptions()...))
+	if _, err := bound.Echo(context.Background(), &pb.EchoRequest{Message: "hi"}); err != nil {
+		t.Fatalf("bound request was rejected: %v", err)
+	}
+
+	unbound := pb.NewEchoClient(dialTLS(t, s, config, signer.DialOptions()...))
+	_, err = unbound.Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+	requireReason(t, err, auth.ReasonInvalidChannelBinding)
+
+	// A client pinning another key refuses the server's certificate.
+	otherPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherConfig, err := tlsutil.PinnedClientConfig(nil, otherPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pb.NewEchoClient(dialTLS(t, s, otherConfig, signer.DialOptions()...)).Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable for an unpinned server, got %v", err)
+	}
+
+	c := dialClient(t, s,
+		client.WithSigner(clientPrivateKey),
+		client.WithTLS(config),
+		client.WithChannelBinding(),
+		client.WithServerKey(serverPublicKey),
+	)
+	if _, err := c.Echo(context.Background(), "Hello World"); err != nil {
+		t.Fatalf("client request over TLS failed: %v", err)
+	}
+}
+
+func TestMutualTLSFromPEMFiles(t *testing.T) {
+	clientPublicKey, clientPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientKeyID := signing.Fingerprint(signing.Ed25519, clientPublicKey)
+
+	tks := keystore.NewMemoryKeyStore()
+	tks.StorePublicKey(clientKeyID, clientPublicKey)
+
+	dir := t.TempDir()
+	serverCert, serverKey := writeCertificate(t, dir, "server")
+	clientCert, clientKey := writeCertificate(t, dir, "client")
+
+	s := newServer(t, tks, server.WithMutualTLS(serverCert, serverKey, clientCert))
+	config, err := tlsutil.ClientConfig(serverCert, clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(t, s)
+
+	signer := auth.NewSigner(clientKeyID, clientPrivateKey)
+	echo := pb.NewEchoClient(dialTLS(t, s, config, signer.DialOptions()...))
+	if _, err := echo.Echo(context.Background(), &pb.EchoRequest{Message: "hi"}); err != nil {
+		t.Fatalf("mutual TLS request was rejected: %v", err)
+	}
+
+	// Clients without a certificate cannot connect.
+	anonymous, err := tlsutil.ClientConfig(serverCert, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pb.NewEchoClient(dialTLS(t, s, anonymous, signer.DialOptions()...)).Echo(context.Background(), &pb.EchoRequest{Message: "hi"})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable without a client certificate, got %v", err)
+	}
+}
+
+func TestChannelBindingRejectsQuorumOverTLS(t *testing.T) {
+	serverPublicKey, serverPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminPublicKey, adminPrivateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminKeyID := signing.Fingerprint(signing.Ed25519, adminPublicKey)
+	adminKeys := keystore.NewMemoryKeyStore()
+	adminKeys.StorePublicKey(adminKeyID, adminPublicKey)
+
+	s := newServer(t, keystore.NewMemoryKeyStore(),
+		server.WithSelfSignedTLS(serverPrivateKey, "127.0.0.1"),
+		server.WithChannelBinding(),
+		server.WithAdminKeys(adminKeys),
+		server.WithQuorum("/services.KeyAdmin/ListKeys", 1, ""),
+	)
+	serve(t, s)
+
+	config, err := tlsutil.PinnedClientConfig(nil, serverPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := dialTLS(t, s, config)
+
+	// The envelope is valid, but it was signed before the connection existed
+	// and so cannot be bound to it.
+	req := &pb.ListKeysRequest{}
+	envelope, err := multisig.NewEnvelope("/services.KeyAdmin/ListKeys", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := envelope.Sign(adminKeyID, adminPrivateKey); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := envelope.AppendToOutgoingContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pb.NewKeyAdminClient(conn).ListKeys(ctx, req)
+	requireReason(t, err, auth.ReasonInvalidChannelBinding)
+}
+
+func dialTLS(t *testing.T, s *server.Server, config *tls.Config, opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.Dial(s.Addr().String(), append([]grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(config))}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// writeCertificate writes a self-signed certificate for 127.0.0.1 and its key
+// as PEM files in dir.
+func writeCertificate(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tlsutil.SelfSignedCertificate(privateKey, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
@@ -0,0 +1,163 @@
+package keyutils
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// Encrypted private keys are stored as a PEM block of this type. The block
+// holds a header followed by the ciphertext:
+//
+//	magic    "GAAK"
+//	version  1 byte
+//	log2 N   1 byte, scrypt cost
+//	r        4 bytes big-endian, scrypt block size
+//	p        4 bytes big-endian, scrypt parallelism
+//	salt     16 bytes
+//	nonce    24 bytes
+//
+// Version 1 derives a 32 byte key with scrypt and seals the private key with
+// XChaCha20-Poly1305, using the header as additional data so the parameters
+// cannot be altered. The plaintext is a raw ed25519 private key or, for other
+// algorithms, PKCS #8 DER.
+const encryptedKeyBlockType = "GRPC-APP-AUTH ENCRYPTED PRIVATE KEY"
+
+const (
+	encryptedKeyMagic   = "GAAK"
+	encryptedKeyVersion = 1
+
+	saltSize   = 16
+	headerSize = len(encryptedKeyMagic) + 1 + 1 + 4 + 4 + saltSize + chacha20poly1305.NonceSizeX
+
+	// Interactive scrypt parameters, about 100ms on current hardware.
+	defaultScryptLogN = 15
+	defaultScryptR    = 8
+	defaultScryptP    = 1
+
+	// Bounds on parameters read from a file so that a crafted header cannot
+	// make decryption take minutes or gigabytes.
+	maxScryptLogN   = 20
+	maxScryptP      = 16
+	maxScryptMemory = 256 << 20
+)
+
+var (
+	ErrPassphraseRequired = errors.New("private key is encrypted and needs a passphrase")
+	ErrWrongPassphrase    = errors.New("wrong passphrase or corrupted private key")
+)
+
+// EncryptKey returns key encrypted under passphrase as a PEM block.
+func EncryptKey(key []byte, passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("passphrase must not be empty")
+	}
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, encryptedKeyMagic...)
+	header = append(header, encryptedKeyVersion, defaultScryptLogN)
+	header = binary.BigEndian.AppendUint32(header, defaultScryptR)
+	header = binary.BigEndian.AppendUint32(header, defaultScryptP)
+
+	random := make([]byte, saltSize+chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	header = append(header, random...)
+
+	aead, err := newAEAD(header, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := header[headerSize-chacha20poly1305.NonceSizeX:]
+	sealed := aead.Seal(append([]byte(nil), header...), nonce, key, header)
+	return pem.EncodeToMemory(&pem.Block{Type: encryptedKeyBlockType, Bytes: sealed}), nil
+}
+
+// DecryptKey reverses EncryptKey.
+func DecryptKey(data []byte, passphrase []byte) ([]byte, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != encryptedKeyBlockType {
+		return nil, fmt.Errorf("not an encrypted private key")
+	}
+
+	sealed := block.Bytes
+	if len(sealed) < headerSize || !bytes.HasPrefix(sealed, []byte(encryptedKeyMagic)) {
+		return nil, fmt.Errorf("malformed encrypted private key")
+	}
+	if version := sealed[len(encryptedKeyMagic)]; version != encryptedKeyVersion {
+		return nil, fmt.Errorf("unsupported encrypted private key version %d", version)
+	}
+	if len(passphrase) == 0 {
+		return nil, ErrPassphraseRequired
+	}
+
+	header := sealed[:headerSize]
+	aead, err := newAEAD(header, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := header[headerSize-chacha20poly1305.NonceSizeX:]
+	key, err := aead.Open(nil, nonce, sealed[headerSize:], header)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return key, nil
+}
+
+// IsEncryptedKey reports whether data was produced by EncryptKey.
+func IsEncryptedKey(data []byte) bool {
+	block, _ := pem.Decode(data)
+	return block != nil && block.Type == encryptedKeyBlockType
+}
+
+// ReadPassphrase returns the passphrase in the environment variable env or,
+// if it is unset and stdin is a terminal, prompts for one.
+func ReadPassphrase(env string) ([]byte, error) {
+	if passphrase, ok := os.LookupEnv(env); ok {
+		return []byte(passphrase), nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("%s is not set and stdin is not a terminal", env)
+	}
+
+	fmt.Fprint(os.Stderr, "Private key passphrase: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return passphrase, err
+}
+
+// newAEAD derives the key encryption key from the scrypt parameters and salt
+// in header.
+func newAEAD(header []byte, passphrase []byte) (cipher.AEAD, error) {
+	params := header[len(encryptedKeyMagic)+1:]
+	logN := params[0]
+	r := binary.BigEndian.Uint32(params[1:5])
+	p := binary.BigEndian.Uint32(params[5:9])
+	salt := params[9 : 9+saltSize]
+
+	// scrypt uses 128 * r * N bytes of memory.
+	if logN < 1 || logN > maxScryptLogN || r < 1 || p < 1 || p > maxScryptP ||
+		uint64(r) > maxScryptMemory>>(7+logN) {
+		return nil, fmt.Errorf("scrypt parameters out of range")
+	}
+
+	key, err := scrypt.Key(passphrase, salt, 1<<logN, int(r), int(p), chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.NewX(key)
+}
@@ -0,0 +1,58 @@
+package keyutils
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+func TestEncryptKeyRoundTrip(t *testing.T) {
+	key := []byte("private key bytes")
+
+	encrypted, err := EncryptKey(key, []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncryptedKey(encrypted) {
+		t.Fatal("expected output to be recognised as an encrypted key")
+	}
+	if bytes.Contains(encrypted, key) {
+		t.Fatal("expected key not to appear in the output")
+	}
+
+	decrypted, err := DecryptKey(encrypted, []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, key) {
+		t.Fatalf("expected %q, got %q", key, decrypted)
+	}
+
+	if _, err := DecryptKey(encrypted, []byte("battery staple")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("expected ErrWrongPassphrase, got %v", err)
+	}
+	if _, err := DecryptKey(encrypted, nil); !errors.Is(err, ErrPassphraseRequired) {
+		t.Fatalf("expected ErrPassphraseRequired, got %v", err)
+	}
+}
+
+func TestEncryptedKeyHeaderIsAuthenticated(t *testing.T) {
+	encrypted, err := EncryptKey([]byte("private key bytes"), []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(encrypted)
+
+	// Any change to the header, here the salt, makes decryption fail.
+	block.Bytes[len(encryptedKeyMagic)+1+1+4+4] ^= 1
+	if _, err := DecryptKey(pem.EncodeToMemory(block), []byte("correct horse")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("expected tampering to be detected, got %v", err)
+	}
+
+	// A version from the future is refused rather than misread.
+	block.Bytes[len(encryptedKeyMagic)] = encryptedKeyVersion + 1
+	if _, err := DecryptKey(pem.EncodeToMemory(block), []byte("correct horse")); err == nil {
+		t.Fatal("expected unknown version to be rejected")
+	}
+}
@@ -0,0 +1,191 @@
+package keyutils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+
+	"grpc-app-auth/signing"
+)
+
+const (
+	pkcs8BlockType = "PRIVATE KEY"
+	pkixBlockType  = "PUBLIC KEY"
+)
+
+// MarshalPKCS8PEM returns key as a PKCS #8 "PRIVATE KEY" PEM block.
+func MarshalPKCS8PEM(key crypto.Signer) ([]byte, error) {
+	if _, err := signing.AlgorithmOf(key.Public()); err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pkcs8BlockType, Bytes: der}), nil
+}
+
+// ParsePKCS8PEM parses a PKCS #8 "PRIVATE KEY" PEM block holding a key for
+// one of the supported signature algorithms.
+func ParsePKCS8PEM(data []byte) (crypto.Signer, error) {
+	block, err := decodePEM(data, pkcs8BlockType)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePKCS8DER(block.Bytes)
+}
+
+func parsePKCS8DER(der []byte) (crypto.Signer, error) {
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("malformed PKCS #8 key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	if _, err := signing.AlgorithmOf(signer.Public()); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// MarshalPKIXPEM returns publicKey as a PKIX "PUBLIC KEY" PEM block.
+func MarshalPKIXPEM(publicKey crypto.PublicKey) ([]byte, error) {
+	if _, err := signing.AlgorithmOf(publicKey); err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pkixBlockType, Bytes: der}), nil
+}
+
+// ParsePKIXPEM parses a PKIX "PUBLIC KEY" PEM block holding a key for one of
+// the supported signature algorithms.
+func ParsePKIXPEM(data []byte) (crypto.PublicKey, error) {
+	block, err := decodePEM(data, pkixBlockType)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("malformed PKIX key: %w", err)
+	}
+	if _, err := signing.AlgorithmOf(publicKey); err != nil {
+		return nil, err
+	}
+	return publicKey, nil
+}
+
+// ParsePrivateKey parses a private key in any supported format: raw base64
+// ed25519, an encrypted key from EncryptKey, PKCS #8 PEM, OpenSSH or JWK.
+// passphrase is only used for encrypted keys.
+func ParsePrivateKey(data []byte, passphrase []byte) (crypto.Signer, error) {
+	data = bytes.TrimSpace(data)
+
+	var key ed25519.PrivateKey
+	var err error
+	switch {
+	case IsEncryptedKey(data):
+		raw, err := DecryptKey(data, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) != ed25519.PrivateKeySize {
+			return parsePKCS8DER(raw)
+		}
+		key, err = rawPrivateKey(raw)
+		if err != nil {
+			return nil, err
+		}
+	case bytes.HasPrefix(data, []byte("-----BEGIN "+openSSHBlockType)):
+		key, err = ParseOpenSSHPrivateKey(data, passphrase)
+	case bytes.HasPrefix(data, []byte("-----BEGIN ")):
+		return ParsePKCS8PEM(data)
+	case bytes.HasPrefix(data, []byte("{")):
+		var jwk JWK
+		if jwk, err = ParseJWK(data); err == nil {
+			key, err = jwk.PrivateKey()
+		}
+	default:
+		var raw []byte
+		if raw, err = base64.StdEncoding.DecodeString(string(data)); err != nil {
+			return nil, fmt.Errorf("unrecognised private key format")
+		}
+		key, err = rawPrivateKey(raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ParsePublicKey parses a public key in any supported format: raw base64
+// ed25519, PKIX PEM, an OpenSSH authorized key or JWK.
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	data = bytes.TrimSpace(data)
+
+	var key ed25519.PublicKey
+	var err error
+	switch {
+	case bytes.HasPrefix(data, []byte("-----BEGIN ")):
+		return ParsePKIXPEM(data)
+	case bytes.HasPrefix(data, []byte(sshEd25519+" ")):
+		key, _, err = ParseAuthorizedKey(data)
+	case bytes.HasPrefix(data, []byte("{")):
+		var jwk JWK
+		if jwk, err = ParseJWK(data); err == nil {
+			key, err = jwk.PublicKey()
+		}
+	default:
+		var raw []byte
+		if raw, err = base64.StdEncoding.DecodeString(string(data)); err != nil {
+			return nil, fmt.Errorf("unrecognised public key format")
+		}
+		if len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("ed25519 public key must be %d bytes, got %d", ed25519.PublicKeySize, len(raw))
+		}
+		key = raw
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// rawPrivateKey checks that key is a raw ed25519 private key, whose second
+// half must be the public key derived from its seed.
+func rawPrivateKey(key []byte) (ed25519.PrivateKey, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("ed25519 private key must be %d bytes, got %d", ed25519.PrivateKeySize, len(key))
+	}
+
+	privateKey := ed25519.PrivateKey(key)
+	derived := ed25519.NewKeyFromSeed(privateKey.Seed())
+	if !bytes.Equal(derived, privateKey) {
+		return nil, fmt.Errorf("ed25519 private key does not match its public half")
+	}
+	return privateKey, nil
+}
+
+func decodePEM(data []byte, blockType string) (*pem.Block, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	if block.Type != blockType {
+		return nil, fmt.Errorf("expected %q PEM block, got %q", blockType, block.Type)
+	}
+	return block, nil
+}
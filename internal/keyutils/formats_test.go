@@ -0,0 +1,190 @@
+package keyutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestPEMRoundTrip(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privatePEM, err := MarshalPKCS8PEM(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParsePrivateKey(privatePEM, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !edKey.Equal(parsed) {
+		t.Fatal("PKCS #8 ed25519 key did not round trip")
+	}
+
+	publicPEM, err := MarshalPKIXPEM(&ecKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedPublic, err := ParsePublicKey(publicPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ecKey.PublicKey.Equal(parsedPublic) {
+		t.Fatal("PKIX ECDSA key did not round trip")
+	}
+
+	if _, err := ParsePKCS8PEM(publicPEM); err == nil {
+		t.Fatal("expected a public key PEM block to be rejected as a private key")
+	}
+}
+
+func TestOpenSSHRoundTrip(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := MarshalAuthorizedKey(publicKey, "build agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedPublic, comment, err := ParseAuthorizedKey(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !publicKey.Equal(parsedPublic) || comment != "build agent" {
+		t.Fatalf("authorized key did not round trip: %s", line)
+	}
+
+	privatePEM, err := MarshalOpenSSHPrivateKey(privateKey, "build agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The output must be readable by other OpenSSH implementations.
+	sshSigner, err := ssh.ParsePrivateKey(privatePEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(sshSigner.PublicKey().Marshal()) != string(mustSSHKey(t, publicKey).Marshal()) {
+		t.Fatal("x/crypto/ssh read a different public key")
+	}
+
+	parsed, err := ParsePrivateKey(privatePEM, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !privateKey.Equal(parsed) {
+		t.Fatal("OpenSSH private key did not round trip")
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecSSHKey, err := ssh.NewPublicKey(&ecKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ParseAuthorizedKey(ssh.MarshalAuthorizedKey(ecSSHKey)); err == nil {
+		t.Fatal("expected a non ed25519 OpenSSH key to be rejected")
+	}
+}
+
+func mustSSHKey(t *testing.T, publicKey ed25519.PublicKey) ssh.PublicKey {
+	t.Helper()
+	sshKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sshKey
+}
+
+func TestJWK(t *testing.T) {
+	// RFC 8037 appendix A.1.
+	rfcKey := []byte(`{"kty":"OKP","crv":"Ed25519",
+		"d":"nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A",
+		"x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`)
+
+	jwk, err := ParseJWK(rfcKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey, err := jwk.PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reencoded, err := NewPrivateJWK(privateKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reencoded.D != jwk.D || reencoded.X != jwk.X {
+		t.Fatalf("expected %+v, got %+v", jwk, reencoded)
+	}
+
+	jwks, err := ParseJWKS([]byte(`{"keys":[{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo","kid":"one"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != "one" {
+		t.Fatalf("unexpected JWKS %+v", jwks)
+	}
+
+	for name, invalid := range map[string]string{
+		"wrong curve":    `{"kty":"OKP","crv":"X25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`,
+		"wrong type":     `{"kty":"EC","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`,
+		"short x":        `{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg"}`,
+		"mismatched d":   `{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo","d":"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}`,
+		"wrong alg":      `{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo","alg":"ES256"}`,
+		"encryption use": `{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo","use":"enc"}`,
+	} {
+		if _, err := ParseJWK([]byte(invalid)); err == nil {
+			t.Errorf("%s: expected JWK to be rejected", name)
+		}
+	}
+}
+
+func TestRawKeysAreValidated(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParsePublicKey([]byte(base64.StdEncoding.EncodeToString(publicKey[:31]))); err == nil {
+		t.Fatal("expected a truncated public key to be rejected")
+	}
+	if _, err := ParsePrivateKey([]byte(base64.StdEncoding.EncodeToString(privateKey[:32])), nil); err == nil {
+		t.Fatal("expected a bare seed to be rejected")
+	}
+
+	// A private key whose public half belongs to another key.
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mixed := append(append([]byte(nil), privateKey[:32]...), otherKey[32:]...)
+	if _, err := ParsePrivateKey([]byte(base64.StdEncoding.EncodeToString(mixed)), nil); err == nil {
+		t.Fatal("expected a mismatched private key to be rejected")
+	}
+
+	parsed, err := ParsePrivateKey([]byte(base64.StdEncoding.EncodeToString(privateKey)+"\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !privateKey.Equal(parsed) {
+		t.Fatal("raw private key did not round trip")
+	}
+}
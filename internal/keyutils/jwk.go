@@ -0,0 +1,158 @@
+package keyutils
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// JWK is a JSON Web Key (RFC 8037) for an Ed25519 key. D is only set for
+// private keys.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	D   string `json:"d,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+const (
+	jwkKeyType   = "OKP"
+	jwkCurve     = "Ed25519"
+	jwkAlgorithm = "EdDSA"
+)
+
+// NewPublicJWK returns the JWK for publicKey.
+func NewPublicJWK(publicKey ed25519.PublicKey, kid string) (JWK, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return JWK{}, fmt.Errorf("ed25519 public key must be %d bytes", ed25519.PublicKeySize)
+	}
+
+	return JWK{
+		Kty: jwkKeyType,
+		Crv: jwkCurve,
+		X:   base64.RawURLEncoding.EncodeToString(publicKey),
+		Kid: kid,
+		Alg: jwkAlgorithm,
+		Use: "sig",
+	}, nil
+}
+
+// NewPrivateJWK returns the JWK for privateKey, including its seed.
+func NewPrivateJWK(privateKey ed25519.PrivateKey, kid string) (JWK, error) {
+	if _, err := rawPrivateKey(privateKey); err != nil {
+		return JWK{}, err
+	}
+
+	jwk, err := NewPublicJWK(privateKey.Public().(ed25519.PublicKey), kid)
+	if err != nil {
+		return JWK{}, err
+	}
+	jwk.D = base64.RawURLEncoding.EncodeToString(privateKey.Seed())
+	return jwk, nil
+}
+
+// ParseJWK parses and validates a single JWK.
+func ParseJWK(data []byte) (JWK, error) {
+	var jwk JWK
+	if err := json.Unmarshal(data, &jwk); err != nil {
+		return JWK{}, fmt.Errorf("malformed JWK: %w", err)
+	}
+	if err := jwk.Validate(); err != nil {
+		return JWK{}, err
+	}
+	return jwk, nil
+}
+
+// ParseJWKS parses a JWK Set, validating every key in it.
+func ParseJWKS(data []byte) (JWKS, error) {
+	var jwks JWKS
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return JWKS{}, fmt.Errorf("malformed JWKS: %w", err)
+	}
+	for i, jwk := range jwks.Keys {
+		if err := jwk.Validate(); err != nil {
+			return JWKS{}, fmt.Errorf("key %d: %w", i, err)
+		}
+	}
+	return jwks, nil
+}
+
+// Validate checks that the JWK is a well formed Ed25519 key whose private
+// part, if present, matches its public part.
+func (j JWK) Validate() error {
+	if j.Kty != jwkKeyType {
+		return fmt.Errorf("unsupported key type %q", j.Kty)
+	}
+	if j.Crv != jwkCurve {
+		return fmt.Errorf("unsupported curve %q", j.Crv)
+	}
+	if j.Alg != "" && j.Alg != jwkAlgorithm {
+		return fmt.Errorf("unsupported algorithm %q", j.Alg)
+	}
+	if j.Use != "" && j.Use != "sig" {
+		return fmt.Errorf("key use must be \"sig\", got %q", j.Use)
+	}
+
+	if _, err := j.PublicKey(); err != nil {
+		return err
+	}
+	if j.D != "" {
+		if _, err := j.PrivateKey(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PublicKey decodes the public key.
+func (j JWK) PublicKey() (ed25519.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(j.X)
+	if err != nil {
+		return nil, fmt.Errorf("malformed x: %w", err)
+	}
+	if len(x) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("x must be %d bytes, got %d", ed25519.PublicKeySize, len(x))
+	}
+	return ed25519.PublicKey(x), nil
+}
+
+// PrivateKey decodes the private key, checking that it matches x.
+func (j JWK) PrivateKey() (ed25519.PrivateKey, error) {
+	if j.D == "" {
+		return nil, fmt.Errorf("JWK has no private key")
+	}
+
+	d, err := base64.RawURLEncoding.DecodeString(j.D)
+	if err != nil {
+		return nil, fmt.Errorf("malformed d: %w", err)
+	}
+	if len(d) != ed25519.SeedSize {
+		return nil, fmt.Errorf("d must be %d bytes, got %d", ed25519.SeedSize, len(d))
+	}
+
+	publicKey, err := j.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey := ed25519.NewKeyFromSeed(d)
+	if !publicKey.Equal(privateKey.Public()) {
+		return nil, fmt.Errorf("d does not match x")
+	}
+	return privateKey, nil
+}
+
+// Public returns the JWK without its private key.
+func (j JWK) Public() JWK {
+	j.D = ""
+	return j
+}
@@ -0,0 +1,250 @@
+package keyutils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"grpc-app-auth/internal/fileutils"
+	"grpc-app-auth/signing"
+)
+
+const (
+	publicKeySuffix  = ".pub"
+	privateKeySuffix = ".key"
+)
+
+var (
+	ErrKeyExists           = errors.New("key pair already exists")
+	ErrInsecurePermissions = errors.New("private key file is readable by group or others")
+)
+
+var keyNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// KeyRing keeps named key pairs in a directory. The pair "client" is stored
+// as client.pub and client.key. Ed25519 keys are written as raw base64 and
+// other keys as PKIX and PKCS #8 PEM; private keys are encrypted when a
+// passphrase is given. Files are written atomically, private keys with mode
+// 0600, and private keys readable by group or others are refused.
+type KeyRing struct {
+	dir string
+}
+
+// NewKeyRing opens the key ring in dir, creating the directory with mode
+// 0700 if it does not exist.
+func NewKeyRing(dir string) (*KeyRing, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &KeyRing{dir: dir}, nil
+}
+
+func (kr *KeyRing) Dir() string {
+	return kr.dir
+}
+
+// Names returns the names of the pairs in the key ring, sorted.
+func (kr *KeyRing) Names() ([]string, error) {
+	entries, err := os.ReadDir(kr.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), publicKeySuffix)
+		if ok && entry.Type().IsRegular() && keyNamePattern.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Generate creates and saves a new ed25519 pair.
+func (kr *KeyRing) Generate(name string, passphrase []byte) (crypto.Signer, error) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := kr.Save(name, privateKey, passphrase); err != nil {
+		return nil, err
+	}
+	return privateKey, nil
+}
+
+// Save stores privateKey and its public key under name, encrypting the
+// private key if passphrase is not empty. It returns ErrKeyExists rather
+// than replace an existing pair.
+func (kr *KeyRing) Save(name string, privateKey crypto.Signer, passphrase []byte) error {
+	publicPath, privatePath, err := kr.paths(name)
+	if err != nil {
+		return err
+	}
+
+	publicData, err := marshalPublicKey(privateKey.Public())
+	if err != nil {
+		return err
+	}
+	privateData, err := marshalPrivateKey(privateKey, passphrase)
+	if err != nil {
+		return err
+	}
+
+	// The private key goes first so that Names never lists half a pair, and
+	// is removed again if the public key cannot be written. Neither write
+	// replaces an existing file, even one created by a concurrent Save.
+	if err := fileutils.WriteFileExclusive(privatePath, privateData, 0600); err != nil {
+		return saveError(name, err)
+	}
+	if err := fileutils.WriteFileExclusive(publicPath, publicData, 0644); err != nil {
+		os.Remove(privatePath)
+		return saveError(name, err)
+	}
+	return nil
+}
+
+// saveError reports a file Save found already in place as ErrKeyExists.
+func saveError(name string, err error) error {
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s: %w", name, ErrKeyExists)
+	}
+	return err
+}
+
+// PublicKey returns the public key of the named pair.
+func (kr *KeyRing) PublicKey(name string) (crypto.PublicKey, error) {
+	publicPath, _, err := kr.paths(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(publicPath)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := ParsePublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", publicPath, err)
+	}
+	return publicKey, nil
+}
+
+// PrivateKey returns the private key of the named pair, checking that it
+// matches the public key. It returns ErrPassphraseRequired if the key is
+// encrypted and passphrase is empty, and ErrInsecurePermissions if the file
+// is readable by group or others.
+func (kr *KeyRing) PrivateKey(name string, passphrase []byte) (crypto.Signer, error) {
+	_, privatePath, err := kr.paths(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPermissions(privatePath); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(privatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := ParsePrivateKey(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", privatePath, err)
+	}
+
+	publicKey, err := kr.PublicKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !equalKeys(privateKey.Public(), publicKey) {
+		return nil, fmt.Errorf("%s does not match its public key", privatePath)
+	}
+	return privateKey, nil
+}
+
+// Delete removes the named pair.
+func (kr *KeyRing) Delete(name string) error {
+	publicPath, privatePath, err := kr.paths(name)
+	if err != nil {
+		return err
+	}
+
+	// The public key goes first so that Names never lists half a pair.
+	if err := os.Remove(publicPath); err != nil {
+		return err
+	}
+	return os.Remove(privatePath)
+}
+
+func (kr *KeyRing) paths(name string) (string, string, error) {
+	if !keyNamePattern.MatchString(name) {
+		return "", "", fmt.Errorf("invalid key name %q", name)
+	}
+	return filepath.Join(kr.dir, name+publicKeySuffix), filepath.Join(kr.dir, name+privateKeySuffix), nil
+}
+
+func marshalPublicKey(publicKey crypto.PublicKey) ([]byte, error) {
+	if key, ok := publicKey.(ed25519.PublicKey); ok {
+		return []byte(base64.StdEncoding.EncodeToString(key) + "\n"), nil
+	}
+	return MarshalPKIXPEM(publicKey)
+}
+
+func marshalPrivateKey(privateKey crypto.Signer, passphrase []byte) ([]byte, error) {
+	if _, err := signing.AlgorithmOf(privateKey.Public()); err != nil {
+		return nil, err
+	}
+
+	key, isEd25519 := privateKey.(ed25519.PrivateKey)
+
+	if len(passphrase) > 0 {
+		// Encrypted keys hold the raw ed25519 key or PKCS #8 DER.
+		raw := []byte(key)
+		if !isEd25519 {
+			der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+			if err != nil {
+				return nil, err
+			}
+			raw = der
+		}
+		return EncryptKey(raw, passphrase)
+	}
+
+	if isEd25519 {
+		return []byte(base64.StdEncoding.EncodeToString(key) + "\n"), nil
+	}
+	return MarshalPKCS8PEM(privateKey)
+}
+
+// checkPermissions refuses private key files that other users can read.
+// Windows does not have Unix permissions, so nothing is checked there.
+func checkPermissions(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("%s has mode %04o, want 0600: %w", path, info.Mode().Perm(), ErrInsecurePermissions)
+	}
+	return nil
+}
+
+func equalKeys(a crypto.PublicKey, b crypto.PublicKey) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
@@ -0,0 +1,170 @@
+package keyutils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestKeyRing(t *testing.T) {
+	ring, err := NewKeyRing(filepath.Join(t.TempDir(), "keys"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := ring.Generate("client", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.Save("hardware", ecKey, []byte("correct horse")); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := ring.Names()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"client", "hardware"}) {
+		t.Fatalf("unexpected names %v", names)
+	}
+
+	loaded, err := ring.PrivateKey("client", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalKeys(loaded.Public(), client.Public()) {
+		t.Fatal("client key did not round trip")
+	}
+
+	if _, err := ring.PrivateKey("hardware", nil); !errors.Is(err, ErrPassphraseRequired) {
+		t.Fatalf("expected ErrPassphraseRequired, got %v", err)
+	}
+	loaded, err = ring.PrivateKey("hardware", []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ecKey.PublicKey.Equal(loaded.Public()) {
+		t.Fatal("encrypted ECDSA key did not round trip")
+	}
+
+	if _, err := ring.Generate("client", nil); !errors.Is(err, ErrKeyExists) {
+		t.Fatalf("expected ErrKeyExists, got %v", err)
+	}
+	if _, err := ring.Generate("../escape", nil); err == nil {
+		t.Fatal("expected a name with a path separator to be rejected")
+	}
+
+	if err := ring.Delete("client"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.PublicKey("client"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected deleted key to be gone, got %v", err)
+	}
+}
+
+func TestKeyRingPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not checked on Windows")
+	}
+
+	ring, err := NewKeyRing(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.Generate("client", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	privatePath := filepath.Join(ring.Dir(), "client.key")
+	info, err := os.Stat(privatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("expected private key mode 0600, got %04o", info.Mode().Perm())
+	}
+
+	if err := os.Chmod(privatePath, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.PrivateKey("client", nil); !errors.Is(err, ErrInsecurePermissions) {
+		t.Fatalf("expected ErrInsecurePermissions, got %v", err)
+	}
+
+	// The public key can still be read.
+	if _, err := ring.PublicKey("client"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyRingSaveNeverReplaces(t *testing.T) {
+	ring, err := NewKeyRing(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Of several concurrent saves under one name exactly one wins, and the
+	// pair on disk is the winner's.
+	const savers = 8
+	keys := make([]crypto.Signer, savers)
+	errs := make([]error, savers)
+	var wg sync.WaitGroup
+	for i := range keys {
+		_, keys[i], err = ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = ring.Save("client", keys[i], nil)
+		}(i)
+	}
+	wg.Wait()
+
+	winner := -1
+	for i, err := range errs {
+		switch {
+		case err == nil && winner == -1:
+			winner = i
+		case err == nil:
+			t.Fatalf("saves %d and %d both succeeded", winner, i)
+		case !errors.Is(err, ErrKeyExists):
+			t.Fatalf("expected ErrKeyExists, got %v", err)
+		}
+	}
+	if winner == -1 {
+		t.Fatal("expected one save to succeed")
+	}
+	loaded, err := ring.PrivateKey("client", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !keys[winner].Public().(ed25519.PublicKey).Equal(loaded.Public()) {
+		t.Fatal("the saved pair is not the one that was reported saved")
+	}
+
+	// A stray public key fails the save without leaving a private key behind.
+	if err := os.WriteFile(filepath.Join(ring.Dir(), "stray.pub"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.Generate("stray", nil); !errors.Is(err, ErrKeyExists) {
+		t.Fatalf("expected ErrKeyExists, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(ring.Dir(), "stray.key")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no private key for the failed save, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package keyutils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	sshEd25519       = ssh.KeyAlgoED25519
+	openSSHBlockType = "OPENSSH PRIVATE KEY"
+	openSSHMagic     = "openssh-key-v1\x00"
+)
+
+// MarshalAuthorizedKey returns publicKey as an OpenSSH authorized_keys line,
+// "ssh-ed25519 AAAA... comment".
+func MarshalAuthorizedKey(publicKey ed25519.PublicKey, comment string) ([]byte, error) {
+	sshKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	line := ssh.MarshalAuthorizedKey(sshKey)
+	if comment != "" {
+		line = append(line[:len(line)-1], " "+comment+"\n"...)
+	}
+	return line, nil
+}
+
+// ParseAuthorizedKey parses an OpenSSH ssh-ed25519 public key line, returning
+// the key and its comment.
+func ParseAuthorizedKey(data []byte) (ed25519.PublicKey, string, error) {
+	sshKey, comment, options, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(options) > 0 {
+		return nil, "", fmt.Errorf("authorized key options are not supported")
+	}
+	if sshKey.Type() != sshEd25519 {
+		return nil, "", fmt.Errorf("expected %s key, got %s", sshEd25519, sshKey.Type())
+	}
+
+	publicKey := sshKey.(ssh.CryptoPublicKey).CryptoPublicKey().(ed25519.PublicKey)
+	return publicKey, comment, nil
+}
+
+// MarshalOpenSSHPrivateKey returns privateKey as an unencrypted OpenSSH
+// "OPENSSH PRIVATE KEY" PEM block, as written by ssh-keygen.
+func MarshalOpenSSHPrivateKey(privateKey ed25519.PrivateKey, comment string) ([]byte, error) {
+	if _, err := rawPrivateKey(privateKey); err != nil {
+		return nil, err
+	}
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+
+	sshKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The matching check values let readers detect a wrong passphrase; they
+	// are random for unencrypted keys too.
+	var check [4]byte
+	if _, err := rand.Read(check[:]); err != nil {
+		return nil, err
+	}
+
+	var private []byte
+	private = append(private, check[:]...)
+	private = append(private, check[:]...)
+	private = appendSSHString(private, []byte(sshEd25519))
+	private = appendSSHString(private, publicKey)
+	private = appendSSHString(private, privateKey)
+	private = appendSSHString(private, []byte(comment))
+	// Pad to the cipher block size, 8 for "none", with 1, 2, 3, ...
+	for i := byte(1); len(private)%8 != 0; i++ {
+		private = append(private, i)
+	}
+
+	var out []byte
+	out = append(out, openSSHMagic...)
+	out = appendSSHString(out, []byte("none")) // cipher
+	out = appendSSHString(out, []byte("none")) // kdf
+	out = appendSSHString(out, nil)            // kdf options
+	out = binary.BigEndian.AppendUint32(out, 1)
+	out = appendSSHString(out, sshKey.Marshal())
+	out = appendSSHString(out, private)
+
+	return pem.EncodeToMemory(&pem.Block{Type: openSSHBlockType, Bytes: out}), nil
+}
+
+// ParseOpenSSHPrivateKey parses an OpenSSH ed25519 private key, decrypting it
+// with passphrase if ssh-keygen encrypted it.
+func ParseOpenSSHPrivateKey(data []byte, passphrase []byte) (ed25519.PrivateKey, error) {
+	if _, err := decodePEM(data, openSSHBlockType); err != nil {
+		return nil, err
+	}
+
+	var key interface{}
+	var err error
+	if len(passphrase) > 0 {
+		key, err = ssh.ParseRawPrivateKeyWithPassphrase(data, passphrase)
+	} else {
+		key, err = ssh.ParseRawPrivateKey(data)
+	}
+
+	var missing *ssh.PassphraseMissingError
+	switch {
+	case errors.As(err, &missing):
+		return nil, ErrPassphraseRequired
+	case errors.Is(err, x509.IncorrectPasswordError):
+		return nil, ErrWrongPassphrase
+	case err != nil:
+		return nil, err
+	}
+
+	privateKey, ok := key.(*ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected %s private key, got %T", sshEd25519, key)
+	}
+	return rawPrivateKey(*privateKey)
+}
+
+func appendSSHString(out []byte, s []byte) []byte {
+	out = binary.BigEndian.AppendUint32(out, uint32(len(s)))
+	return append(out, s...)
+}
@@ -0,0 +1,69 @@
+package replay
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrStaleTimestamp = errors.New("timestamp is outside the allowed clock skew window")
+	ErrReplayedNonce  = errors.New("nonce has already been used")
+	ErrCacheFull      = errors.New("nonce cache is full")
+)
+
+// Guard rejects requests whose timestamp falls outside an allowed clock skew
+// window and remembers nonces until their timestamp would be rejected anyway.
+type Guard struct {
+	window     time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time // nonce -> expiry
+}
+
+func NewGuard(window time.Duration, maxEntries int) *Guard {
+	return &Guard{
+		window:     window,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		seen:       make(map[string]time.Time),
+	}
+}
+
+// Check records the nonce and returns an error if the timestamp is outside the
+// window or the nonce was already seen within it.
+func (g *Guard) Check(timestamp time.Time, nonce string) error {
+	now := g.now()
+	if timestamp.Before(now.Add(-g.window)) || timestamp.After(now.Add(g.window)) {
+		return ErrStaleTimestamp
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if expiry, ok := g.seen[nonce]; ok && now.Before(expiry) {
+		return ErrReplayedNonce
+	}
+
+	if len(g.seen) >= g.maxEntries {
+		g.prune(now)
+		if len(g.seen) >= g.maxEntries {
+			return ErrCacheFull
+		}
+	}
+
+	// Once the timestamp is older than the window the request is rejected as
+	// stale, so the nonce no longer needs to be remembered.
+	g.seen[nonce] = timestamp.Add(g.window)
+	return nil
+}
+
+func (g *Guard) prune(now time.Time) {
+	for nonce, expiry := range g.seen {
+		if !now.Before(expiry) {
+			delete(g.seen, nonce)
+		}
+	}
+}
@@ -0,0 +1,469 @@
+package keystore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"grpc-app-auth/internal/fileutils"
+	"grpc-app-auth/signing"
+)
+
+const DefaultReloadInterval = 5 * time.Second
+
+// FileKeyStore serves keys from an authorized-keys style file. Each line holds
+// optional comma separated options, a base64 public key and an optional label:
+//
+//	not-after=2025-01-01T00:00:00Z AAAA... build agent
+//	group=admins,group=ops AAAA... on-call laptop
+//	algorithm=ecdsa-p256-sha256 MFkw... hardware token
+//	revoked AAAA... lost laptop
+//	pending,requested-label=new%20worker AAAA...
+//
+// The supported options are algorithm=<name> (ed25519 when omitted),
+// not-before=<RFC 3339>, not-after=<RFC 3339>, group=<name> (repeatable),
+// revoked, pending and requested-label=<path escaped label>. Keys other than
+// ed25519 are PKIX DER encoded. Blank lines and lines starting with '#' are
+// ignored. The key ID is the key's fingerprint, see signing.Fingerprint.
+//
+// The file is polled for changes and the trusted set is swapped atomically, so
+// keys can be added or revoked without restarting the server. A file that
+// fails to parse is logged and the previous set is kept. Writes through the
+// KeyStore methods rewrite the file atomically and keep its comments.
+type FileKeyStore struct {
+	path     string
+	interval time.Duration
+	records  atomic.Pointer[map[string]KeyRecord]
+
+	// mu serializes reloads and writes to the file.
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+
+	done chan struct{}
+	once sync.Once
+}
+
+type FileKeyStoreOption func(*FileKeyStore) error
+
+// WithReloadInterval sets how often the file is checked for changes.
+func WithReloadInterval(interval time.Duration) FileKeyStoreOption {
+	return func(fks *FileKeyStore) error {
+		if interval <= 0 {
+			return fmt.Errorf("reload interval must be positive")
+		}
+		fks.interval = interval
+		return nil
+	}
+}
+
+// NewFileKeyStore loads the keys in path and starts watching it for changes.
+// Close stops the watcher.
+func NewFileKeyStore(path string, opts ...FileKeyStoreOption) (*FileKeyStore, error) {
+	fks := &FileKeyStore{
+		path:     path,
+		interval: DefaultReloadInterval,
+		done:     make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		if err := opt(fks); err != nil {
+			return nil, fmt.Errorf("error applying option: %w", err)
+		}
+	}
+
+	if err := fks.Reload(); err != nil {
+		return nil, err
+	}
+
+	go fks.watch()
+	return fks, nil
+}
+
+func (fks *FileKeyStore) GetPublicKey(keyID string) ([]byte, error) {
+	record, err := fks.GetKeyRecord(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := record.Validate(time.Now()); err != nil {
+		return nil, err
+	}
+
+	return record.PublicKey, nil
+}
+
+func (fks *FileKeyStore) GetKeyRecord(keyID string) (KeyRecord, error) {
+	record, ok := (*fks.records.Load())[keyID]
+	if !ok {
+		return KeyRecord{}, fmt.Errorf("%s: %w", keyID, ErrKeyNotFound)
+	}
+
+	return copyRecord(record), nil
+}
+
+func (fks *FileKeyStore) StorePublicKey(keyID string, publicKey []byte) error {
+	return fks.StoreKeyRecord(KeyRecord{ID: keyID, PublicKey: publicKey})
+}
+
+// StoreKeyRecord writes the record to the file.
+func (fks *FileKeyStore) StoreKeyRecord(record KeyRecord) error {
+	if err := checkRecord(record); err != nil {
+		return err
+	}
+
+	return fks.update(record.ID, func(KeyRecord, bool) (*KeyRecord, error) {
+		return &record, nil
+	})
+}
+
+// UpdateKeyRecord writes the record returned by fn to the file.
+func (fks *FileKeyStore) UpdateKeyRecord(keyID string, fn func(record KeyRecord, ok bool) (KeyRecord, error)) error {
+	return fks.update(keyID, func(current KeyRecord, ok bool) (*KeyRecord, error) {
+		record, err := updated(keyID, current, ok, fn)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkRecord(record); err != nil {
+			return nil, err
+		}
+		return &record, nil
+	})
+}
+
+func (fks *FileKeyStore) RevokePublicKey(keyID string) error {
+	return fks.update(keyID, func(record KeyRecord, ok bool) (*KeyRecord, error) {
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", keyID, ErrKeyNotFound)
+		}
+		record.Revoked = true
+		return &record, nil
+	})
+}
+
+func (fks *FileKeyStore) DeletePublicKey(keyID string) error {
+	return fks.update(keyID, func(record KeyRecord, ok bool) (*KeyRecord, error) {
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", keyID, ErrKeyNotFound)
+		}
+		return nil, nil
+	})
+}
+
+// RotateKey writes next and the shortened old record to the file in a single
+// write.
+func (fks *FileKeyStore) RotateKey(oldKeyID string, next KeyRecord, oldNotAfter time.Time) error {
+	if err := checkRecord(next); err != nil {
+		return err
+	}
+
+	return fks.updateRecords(func(current map[string]KeyRecord) (map[string]*KeyRecord, error) {
+		old, ok := current[oldKeyID]
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", oldKeyID, ErrKeyNotFound)
+		}
+		if _, ok := current[next.ID]; ok {
+			return nil, fmt.Errorf("%s: %w", next.ID, ErrKeyExists)
+		}
+
+		old, err := retire(old, oldNotAfter)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]*KeyRecord{old.ID: &old, next.ID: &next}, nil
+	})
+}
+
+func (fks *FileKeyStore) ListPublicKeys() ([]KeyRecord, error) {
+	current := *fks.records.Load()
+
+	records := make([]KeyRecord, 0, len(current))
+	for _, record := range current {
+		records = append(records, copyRecord(record))
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records, nil
+}
+
+// Reload reads the file and swaps in its keys.
+func (fks *FileKeyStore) Reload() error {
+	fks.mu.Lock()
+	defer fks.mu.Unlock()
+	return fks.reloadLocked()
+}
+
+// Close stops watching the file.
+func (fks *FileKeyStore) Close() error {
+	fks.once.Do(func() { close(fks.done) })
+	return nil
+}
+
+// update rewrites the line for keyID with the record returned by fn, removing
+// it if fn returns nil and appending it if the key is not in the file yet.
+func (fks *FileKeyStore) update(keyID string, fn func(record KeyRecord, ok bool) (*KeyRecord, error)) error {
+	return fks.updateRecords(func(current map[string]KeyRecord) (map[string]*KeyRecord, error) {
+		record, ok := current[keyID]
+		updated, err := fn(record, ok)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]*KeyRecord{keyID: updated}, nil
+	})
+}
+
+// updateRecords rewrites the file with the changes returned by fn, keyed by
+// key ID. A nil record removes the key and keys not in the file yet are
+// appended.
+func (fks *FileKeyStore) updateRecords(fn func(current map[string]KeyRecord) (map[string]*KeyRecord, error)) error {
+	fks.mu.Lock()
+	defer fks.mu.Unlock()
+
+	// Pick up any edits made since the last poll so they are not overwritten.
+	if err := fks.reloadLocked(); err != nil {
+		return err
+	}
+
+	changes, err := fn(*fks.records.Load())
+	if err != nil {
+		return err
+	}
+
+	contents, err := os.ReadFile(fks.path)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	written := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if record, isKey, _ := parseLine(line); isKey {
+			if updated, ok := changes[record.ID]; ok {
+				if updated != nil && !written[record.ID] {
+					out.WriteString(formatLine(*updated) + "\n")
+					written[record.ID] = true
+				}
+				continue
+			}
+		}
+		out.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	keyIDs := make([]string, 0, len(changes))
+	for keyID := range changes {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+	for _, keyID := range keyIDs {
+		if updated := changes[keyID]; updated != nil && !written[keyID] {
+			out.WriteString(formatLine(*updated) + "\n")
+		}
+	}
+
+	// The file keeps its permissions.
+	info, err := os.Stat(fks.path)
+	if err != nil {
+		return err
+	}
+	if err := fileutils.WriteFileAtomic(fks.path, out.Bytes(), info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	return fks.reloadLocked()
+}
+
+func (fks *FileKeyStore) reloadLocked() error {
+	info, err := os.Stat(fks.path)
+	if err != nil {
+		return err
+	}
+
+	contents, err := os.ReadFile(fks.path)
+	if err != nil {
+		return err
+	}
+
+	records, err := parseAuthorizedKeys(contents)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fks.path, err)
+	}
+
+	fks.records.Store(&records)
+	fks.modTime = info.ModTime()
+	fks.size = info.Size()
+	return nil
+}
+
+func (fks *FileKeyStore) watch() {
+	ticker := time.NewTicker(fks.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-fks.done:
+			return
+		case <-ticker.C:
+			if err := fks.reloadIfChanged(); err != nil {
+				log.Printf("[keystore] Failed to reload %s: %v", fks.path, err)
+			}
+		}
+	}
+}
+
+func (fks *FileKeyStore) reloadIfChanged() error {
+	fks.mu.Lock()
+	defer fks.mu.Unlock()
+
+	info, err := os.Stat(fks.path)
+	if err != nil {
+		return err
+	}
+
+	if info.ModTime().Equal(fks.modTime) && info.Size() == fks.size {
+		return nil
+	}
+
+	return fks.reloadLocked()
+}
+
+func parseAuthorizedKeys(contents []byte) (map[string]KeyRecord, error) {
+	records := make(map[string]KeyRecord)
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		record, isKey, err := parseLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		if isKey {
+			records[record.ID] = record
+		}
+	}
+
+	return records, scanner.Err()
+}
+
+// parseLine parses a single line, reporting whether it holds a key.
+func parseLine(line string) (KeyRecord, bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return KeyRecord{}, false, nil
+	}
+
+	fields := strings.SplitN(line, " ", 3)
+
+	var record KeyRecord
+	publicKey, err := decodeKey(fields[0], signing.Ed25519)
+	if err != nil {
+		// The first field is not an ed25519 key, so it must be options.
+		if len(fields) < 2 {
+			return KeyRecord{}, false, err
+		}
+		if err := parseOptions(fields[0], &record); err != nil {
+			return KeyRecord{}, false, err
+		}
+		fields = strings.SplitN(strings.Join(fields[1:], " "), " ", 2)
+		if publicKey, err = decodeKey(fields[0], record.Algorithm); err != nil {
+			return KeyRecord{}, false, err
+		}
+	}
+
+	record.ID = signing.Fingerprint(record.Algorithm, publicKey)
+	record.PublicKey = publicKey
+	if len(fields) > 1 {
+		record.Label = strings.TrimSpace(strings.Join(fields[1:], " "))
+	}
+	return record, true, nil
+}
+
+func decodeKey(field string, alg signing.Algorithm) ([]byte, error) {
+	publicKey, err := base64.StdEncoding.DecodeString(field)
+	if err != nil {
+		return nil, fmt.Errorf("malformed key: %w", err)
+	}
+	if _, err := signing.ParsePublicKey(alg, publicKey); err != nil {
+		return nil, err
+	}
+	return publicKey, nil
+}
+
+func parseOptions(field string, record *KeyRecord) error {
+	for _, option := range strings.Split(field, ",") {
+		name, value, _ := strings.Cut(option, "=")
+
+		var err error
+		switch name {
+		case "revoked":
+			record.Revoked = true
+		case "pending":
+			record.Pending = true
+		case "requested-label":
+			record.RequestedLabel, err = url.PathUnescape(value)
+		case "algorithm":
+			record.Algorithm, err = signing.ParseAlgorithm(value)
+		case "group":
+			if value == "" {
+				err = fmt.Errorf("group must not be empty")
+			}
+			record.Groups = append(record.Groups, value)
+		case "not-before":
+			record.NotBefore, err = time.Parse(time.RFC3339, value)
+		case "not-after":
+			record.NotAfter, err = time.Parse(time.RFC3339, value)
+		default:
+			err = fmt.Errorf("unknown option %q", name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatLine(record KeyRecord) string {
+	var options []string
+	if record.Algorithm != "" && record.Algorithm != signing.Ed25519 {
+		options = append(options, "algorithm="+string(record.Algorithm))
+	}
+	for _, group := range record.Groups {
+		options = append(options, "group="+group)
+	}
+	if !record.NotBefore.IsZero() {
+		options = append(options, "not-before="+record.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if !record.NotAfter.IsZero() {
+		options = append(options, "not-after="+record.NotAfter.UTC().Format(time.RFC3339))
+	}
+	if record.Revoked {
+		options = append(options, "revoked")
+	}
+	if record.Pending {
+		options = append(options, "pending")
+	}
+	if record.RequestedLabel != "" {
+		options = append(options, "requested-label="+url.PathEscape(record.RequestedLabel))
+	}
+
+	fields := []string{base64.StdEncoding.EncodeToString(record.PublicKey)}
+	if len(options) > 0 {
+		fields = append([]string{strings.Join(options, ",")}, fields...)
+	}
+	if record.Label != "" {
+		fields = append(fields, record.Label)
+	}
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,330 @@
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"grpc-app-auth/signing"
+)
+
+func TestFileKeyStoreReloadsOnChange(t *testing.T) {
+	firstKey, first := newKey(t)
+	secondKey, second := newKey(t)
+
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	writeFile(t, path, "# trusted clients\n"+firstKey+" first client\n")
+
+	fks, err := NewFileKeyStore(path, WithReloadInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fks.Close() })
+
+	if _, err := fks.GetPublicKey(first); err != nil {
+		t.Fatalf("expected first key to be trusted: %v", err)
+	}
+	if record, err := fks.GetKeyRecord(first); err != nil || record.Label != "first client" {
+		t.Fatalf("unexpected record %+v, %v", record, err)
+	}
+
+	// Replace the first key with the second.
+	writeFile(t, path, secondKey+"\n")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, errFirst := fks.GetPublicKey(first)
+		_, errSecond := fks.GetPublicKey(second)
+		if errFirst != nil && errSecond == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("key file change was not picked up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFileKeyStoreKeepsKeysOnParseError(t *testing.T) {
+	key, keyID := newKey(t)
+
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	writeFile(t, path, key+"\n")
+
+	fks, err := NewFileKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fks.Close() })
+
+	writeFile(t, path, "not-a-key\n")
+	if err := fks.Reload(); err == nil {
+		t.Fatal("expected reload of malformed file to fail")
+	}
+
+	if _, err := fks.GetPublicKey(keyID); err != nil {
+		t.Fatalf("expected previous keys to be kept: %v", err)
+	}
+}
+
+func TestFileKeyStoreStorePublicKey(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := signing.Fingerprint(signing.Ed25519, publicKey)
+
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	writeFile(t, path, "")
+
+	fks, err := NewFileKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fks.Close() })
+
+	if err := fks.StorePublicKey(keyID, publicKey); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { reopened.Close() })
+
+	if _, err := reopened.GetPublicKey(keyID); err != nil {
+		t.Fatalf("expected stored key to be persisted: %v", err)
+	}
+
+	if err := fks.StoreKeyRecord(KeyRecord{ID: keyID, PublicKey: publicKey, Pending: true, RequestedLabel: "new, worker"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if record, err := reopened.GetKeyRecord(keyID); err != nil || record.RequestedLabel != "new, worker" || record.Label != "" {
+		t.Fatalf("expected requested label to be persisted, got %+v, %v", record, err)
+	}
+}
+
+// newKey returns a new ed25519 public key as written in the file and its ID.
+func newKey(t *testing.T) (string, string) {
+	t.Helper()
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(publicKey), signing.Fingerprint(signing.Ed25519, publicKey)
+}
+
+func writeFile(t *testing.T, path string, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileKeyStoreRevokeAndDelete(t *testing.T) {
+	revokedKey, revoked := newKey(t)
+	expiringKey, expiring := newKey(t)
+	deletedKey, deleted := newKey(t)
+
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	writeFile(t, path, "# keep this comment\n"+
+		revokedKey+" laptop\n"+
+		"not-after=2000-01-01T00:00:00Z "+expiringKey+" old build agent\n"+
+		deletedKey+"\n")
+
+	fks, err := NewFileKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fks.Close() })
+
+	if _, err := fks.GetPublicKey(expiring); !errors.Is(err, ErrKeyExpired) {
+		t.Fatalf("expected expired key, got %v", err)
+	}
+
+	if err := fks.RevokePublicKey(revoked); err != nil {
+		t.Fatal(err)
+	}
+	if err := fks.DeletePublicKey(deleted); err != nil {
+		t.Fatal(err)
+	}
+	if err := fks.RevokePublicKey(deleted); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected revoking a deleted key to fail, got %v", err)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# keep this comment\n" +
+		"revoked " + revokedKey + " laptop\n" +
+		"not-after=2000-01-01T00:00:00Z " + expiringKey + " old build agent\n"
+	if string(contents) != want {
+		t.Fatalf("unexpected file contents:\n%s", contents)
+	}
+
+	if _, err := fks.GetPublicKey(revoked); !errors.Is(err, ErrKeyRevoked) {
+		t.Fatalf("expected revoked key, got %v", err)
+	}
+	if _, err := fks.GetPublicKey(deleted); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected unknown key, got %v", err)
+	}
+
+	records, err := fks.ListPublicKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+}
+
+func TestFileKeyStoreAlgorithms(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, alg, err := signing.MarshalPublicKey(ecKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(publicKey)
+	keyID := signing.Fingerprint(alg, publicKey)
+
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	writeFile(t, path, "algorithm="+string(alg)+" "+key+" hardware token\n")
+
+	fks, err := NewFileKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fks.Close() })
+
+	record, err := fks.GetKeyRecord(keyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Algorithm != signing.ECDSAP256SHA256 || record.Label != "hardware token" {
+		t.Fatalf("unexpected record %+v", record)
+	}
+
+	// The key only parses with the algorithm it was declared with.
+	writeFile(t, path, key+"\n")
+	if err := fks.Reload(); err == nil {
+		t.Fatal("expected an ECDSA key without an algorithm to be rejected")
+	}
+
+	wrongAlg := KeyRecord{ID: signing.Fingerprint(signing.RSAPSSSHA256, publicKey), PublicKey: publicKey, Algorithm: signing.RSAPSSSHA256}
+	if err := fks.StoreKeyRecord(wrongAlg); err == nil {
+		t.Fatal("expected a key stored with the wrong algorithm to be rejected")
+	}
+	if err := fks.StoreKeyRecord(KeyRecord{ID: key, PublicKey: publicKey, Algorithm: alg}); err == nil {
+		t.Fatal("expected a key ID that is not the fingerprint to be rejected")
+	}
+}
+
+func TestFileKeyStoreRotateKey(t *testing.T) {
+	oldKey, oldKeyID := newKey(t)
+	newPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := KeyRecord{ID: signing.Fingerprint(signing.Ed25519, newPublicKey), PublicKey: newPublicKey, Label: "laptop"}
+
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	writeFile(t, path, "group=staff "+oldKey+" laptop\n")
+
+	fks, err := NewFileKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fks.Close() })
+
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := fks.RotateKey(oldKeyID, next, notAfter); err != nil {
+		t.Fatal(err)
+	}
+	if err := fks.RotateKey(oldKeyID, next, notAfter); !errors.Is(err, ErrKeyExists) {
+		t.Fatalf("expected ErrKeyExists, got %v", err)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "group=staff,not-after=" + notAfter.UTC().Format(time.RFC3339) + " " + oldKey + " laptop\n" +
+		base64.StdEncoding.EncodeToString(newPublicKey) + " laptop\n"
+	if string(contents) != want {
+		t.Fatalf("unexpected file contents:\n%s", contents)
+	}
+
+	if err := fks.RevokePublicKey(next.ID); err != nil {
+		t.Fatal(err)
+	}
+	otherPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := KeyRecord{ID: signing.Fingerprint(signing.Ed25519, otherPublicKey), PublicKey: otherPublicKey}
+	if err := fks.RotateKey(next.ID, other, notAfter); !errors.Is(err, ErrKeyRevoked) {
+		t.Fatalf("expected a revoked key not to be rotated, got %v", err)
+	}
+}
+
+func TestFileKeyStoreUpdateKeyRecord(t *testing.T) {
+	pendingKey, pending := newKey(t)
+	invalid := signing.Fingerprint(signing.Ed25519, []byte("not a key"))
+
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	writeFile(t, path, "# keep this comment\npending,requested-label=worker "+pendingKey+"\n")
+
+	fks, err := NewFileKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fks.Close() })
+
+	err = fks.UpdateKeyRecord(pending, func(record KeyRecord, ok bool) (KeyRecord, error) {
+		if !ok {
+			return KeyRecord{}, ErrKeyNotFound
+		}
+		record.Pending = false
+		record.Label, record.RequestedLabel = record.RequestedLabel, ""
+		return record, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# keep this comment\n" + pendingKey + " worker\n"; string(contents) != want {
+		t.Fatalf("unexpected file contents:\n%s", contents)
+	}
+
+	err = fks.UpdateKeyRecord(invalid, func(record KeyRecord, ok bool) (KeyRecord, error) {
+		if ok {
+			t.Fatal("expected no record for a new key")
+		}
+		return KeyRecord{ID: invalid, PublicKey: []byte("not a key")}, nil
+	})
+	if !errors.Is(err, ErrInvalidRecord) {
+		t.Fatalf("expected an invalid record to be rejected, got %v", err)
+	}
+	if _, err := fks.GetKeyRecord(invalid); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected nothing to be stored, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package keystore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"grpc-app-auth/signing"
+)
+
+var (
+	ErrKeyNotFound    = errors.New("key not found")
+	ErrKeyRevoked     = errors.New("key has been revoked")
+	ErrKeyExpired     = errors.New("key has expired")
+	ErrKeyNotYetValid = errors.New("key is not yet valid")
+	ErrKeyPending     = errors.New("key is awaiting approval")
+	ErrKeyExists      = errors.New("key already exists")
+	// ErrInvalidRecord is returned when a store rejects a record, such as one
+	// whose ID is not its key's fingerprint.
+	ErrInvalidRecord = errors.New("invalid key record")
+)
+
+// KeyRecord is a trusted public key and the conditions under which it may be used.
+type KeyRecord struct {
+	// ID is the key's fingerprint, see signing.Fingerprint.
+	ID        string
+	PublicKey []byte
+	// Algorithm is the algorithm PublicKey is used with. The empty value
+	// means signing.Ed25519.
+	Algorithm signing.Algorithm
+	Label     string
+	// Groups name the sets of keys this key belongs to, for use by
+	// authorization policies.
+	Groups []string
+	// NotBefore and NotAfter bound the key's validity. The zero time leaves
+	// that side of the window open.
+	NotBefore time.Time
+	NotAfter  time.Time
+	Revoked   bool
+	// Pending keys were enrolled by a client and wait for an admin to approve them.
+	Pending bool
+	// RequestedLabel is the label a client asked for when enrolling. It is
+	// not trusted, and so not used for authorization, until an admin
+	// approves the key and it becomes Label.
+	RequestedLabel string
+}
+
+// Validate returns ErrKeyRevoked, ErrKeyPending, ErrKeyNotYetValid or
+// ErrKeyExpired if the key may not be used at the given time.
+func (r *KeyRecord) Validate(now time.Time) error {
+	switch {
+	case r.Revoked:
+		return ErrKeyRevoked
+	case r.Pending:
+		return ErrKeyPending
+	case !r.NotBefore.IsZero() && now.Before(r.NotBefore):
+		return ErrKeyNotYetValid
+	case !r.NotAfter.IsZero() && !now.Before(r.NotAfter):
+		return ErrKeyExpired
+	}
+	return nil
+}
+
+// KeyStore holds trusted keys by ID. Stores reject records whose ID is not the
+// fingerprint of their public key, whose key is malformed or whose labels or
+// groups could not be written to an authorized keys file, with errors
+// matching ErrInvalidRecord. Records returned share no memory with the store.
+type KeyStore interface {
+	// GetPublicKey returns the public key for the given key ID if it is
+	// currently valid. Errors match ErrKeyNotFound or one of the errors
+	// returned by KeyRecord.Validate.
+	GetPublicKey(keyID string) ([]byte, error)
+
+	// GetKeyRecord returns the record for the given key ID regardless of
+	// whether it is currently valid.
+	GetKeyRecord(keyID string) (KeyRecord, error)
+
+	// StorePublicKey trusts the ed25519 key with no expiry, replacing any
+	// existing record.
+	StorePublicKey(keyID string, publicKey []byte) error
+
+	// StoreKeyRecord adds or replaces the record with the same ID.
+	StoreKeyRecord(record KeyRecord) error
+
+	// UpdateKeyRecord calls fn with the record for keyID, or with ok false if
+	// there is none, and stores the record fn returns in the same update. If
+	// fn returns an error nothing is stored and UpdateKeyRecord returns it.
+	UpdateKeyRecord(keyID string, fn func(record KeyRecord, ok bool) (KeyRecord, error)) error
+
+	// RevokePublicKey marks the key as revoked. Revoked keys are kept so that
+	// they are reported as revoked rather than unknown.
+	RevokePublicKey(keyID string) error
+
+	// DeletePublicKey removes the key entirely.
+	DeletePublicKey(keyID string) error
+
+	// RotateKey stores next and, in the same update, makes the key oldKeyID
+	// expire no later than oldNotAfter. It fails with ErrKeyNotFound if
+	// oldKeyID is unknown, with the error from KeyRecord.Validate if it may
+	// not be used now, and with ErrKeyExists if next is already stored.
+	RotateKey(oldKeyID string, next KeyRecord, oldNotAfter time.Time) error
+
+	// ListPublicKeys returns every record, including revoked and expired
+	// ones, sorted by key ID.
+	ListPublicKeys() ([]KeyRecord, error)
+}
+
+// copyRecord returns a record that shares no memory with r.
+func copyRecord(r KeyRecord) KeyRecord {
+	r.PublicKey = append([]byte(nil), r.PublicKey...)
+	r.Groups = append([]string(nil), r.Groups...)
+	return r
+}
+
+// updated returns the record fn makes of current for use by UpdateKeyRecord.
+func updated(keyID string, current KeyRecord, ok bool, fn func(KeyRecord, bool) (KeyRecord, error)) (KeyRecord, error) {
+	record, err := fn(copyRecord(current), ok)
+	if err != nil {
+		return KeyRecord{}, err
+	}
+	if record.ID != keyID {
+		return KeyRecord{}, fmt.Errorf("%w: updated record %s must keep the key ID %s", ErrInvalidRecord, record.ID, keyID)
+	}
+	return record, nil
+}
+
+// retire returns the record for the old key in a rotation, checking that it
+// may still be used and shortening its validity to end at notAfter.
+func retire(old KeyRecord, notAfter time.Time) (KeyRecord, error) {
+	if err := old.Validate(time.Now()); err != nil {
+		return KeyRecord{}, fmt.Errorf("%s: %w", old.ID, err)
+	}
+	if old.NotAfter.IsZero() || notAfter.Before(old.NotAfter) {
+		old.NotAfter = notAfter
+	}
+	return old, nil
+}
+
+// checkRecord returns an error matching ErrInvalidRecord unless record.ID is
+// the fingerprint of a well-formed public key and its labels and groups can
+// be written to an authorized keys file.
+func checkRecord(record KeyRecord) error {
+	if want := signing.Fingerprint(record.Algorithm, record.PublicKey); record.ID != want {
+		return fmt.Errorf("%w: key ID must be the key's fingerprint %s", ErrInvalidRecord, want)
+	}
+	if _, err := signing.ParsePublicKey(record.Algorithm, record.PublicKey); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+	}
+	if strings.ContainsAny(record.Label, "\r\n") || strings.ContainsAny(record.RequestedLabel, "\r\n") {
+		return fmt.Errorf("%w: label must be a single line", ErrInvalidRecord)
+	}
+	for _, group := range record.Groups {
+		if group == "" || strings.ContainsAny(group, ", \t\r\n") {
+			return fmt.Errorf("%w: invalid group name %q", ErrInvalidRecord, group)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package keystore
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryKeyStore is an in-memory KeyStore that is safe for concurrent use.
+type MemoryKeyStore struct {
+	mu      sync.RWMutex
+	records map[string]KeyRecord
+}
+
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{records: make(map[string]KeyRecord)}
+}
+
+func (mks *MemoryKeyStore) GetPublicKey(keyID string) ([]byte, error) {
+	record, err := mks.GetKeyRecord(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := record.Validate(time.Now()); err != nil {
+		return nil, err
+	}
+
+	return record.PublicKey, nil
+}
+
+func (mks *MemoryKeyStore) GetKeyRecord(keyID string) (KeyRecord, error) {
+	mks.mu.RLock()
+	defer mks.mu.RUnlock()
+
+	record, ok := mks.records[keyID]
+	if !ok {
+		return KeyRecord{}, fmt.Errorf("%s: %w", keyID, ErrKeyNotFound)
+	}
+
+	return copyRecord(record), nil
+}
+
+func (mks *MemoryKeyStore) StorePublicKey(keyID string, publicKey []byte) error {
+	return mks.StoreKeyRecord(KeyRecord{ID: keyID, PublicKey: publicKey})
+}
+
+func (mks *MemoryKeyStore) StoreKeyRecord(record KeyRecord) error {
+	if err := checkRecord(record); err != nil {
+		return err
+	}
+
+	// Copy so later changes to the caller's slice can't race with readers.
+	record = copyRecord(record)
+
+	mks.mu.Lock()
+	defer mks.mu.Unlock()
+
+	mks.records[record.ID] = record
+	return nil
+}
+
+func (mks *MemoryKeyStore) UpdateKeyRecord(keyID string, fn func(record KeyRecord, ok bool) (KeyRecord, error)) error {
+	mks.mu.Lock()
+	defer mks.mu.Unlock()
+
+	current, ok := mks.records[keyID]
+	record, err := updated(keyID, current, ok, fn)
+	if err != nil {
+		return err
+	}
+	if err := checkRecord(record); err != nil {
+		return err
+	}
+
+	mks.records[keyID] = copyRecord(record)
+	return nil
+}
+
+func (mks *MemoryKeyStore) RevokePublicKey(keyID string) error {
+	mks.mu.Lock()
+	defer mks.mu.Unlock()
+
+	record, ok := mks.records[keyID]
+	if !ok {
+		return fmt.Errorf("%s: %w", keyID, ErrKeyNotFound)
+	}
+
+	record.Revoked = true
+	mks.records[keyID] = record
+	return nil
+}
+
+func (mks *MemoryKeyStore) DeletePublicKey(keyID string) error {
+	mks.mu.Lock()
+	defer mks.mu.Unlock()
+
+	if _, ok := mks.records[keyID]; !ok {
+		return fmt.Errorf("%s: %w", keyID, ErrKeyNotFound)
+	}
+
+	delete(mks.records, keyID)
+	return nil
+}
+
+func (mks *MemoryKeyStore) RotateKey(oldKeyID string, next KeyRecord, oldNotAfter time.Time) error {
+	if err := checkRecord(next); err != nil {
+		return err
+	}
+	next = copyRecord(next)
+
+	mks.mu.Lock()
+	defer mks.mu.Unlock()
+
+	old, ok := mks.records[oldKeyID]
+	if !ok {
+		return fmt.Errorf("%s: %w", oldKeyID, ErrKeyNotFound)
+	}
+	if _, ok := mks.records[next.ID]; ok {
+		return fmt.Errorf("%s: %w", next.ID, ErrKeyExists)
+	}
+
+	old, err := retire(old, oldNotAfter)
+	if err != nil {
+		return err
+	}
+
+	mks.records[old.ID] = old
+	mks.records[next.ID] = next
+	return nil
+}
+
+func (mks *MemoryKeyStore) ListPublicKeys() ([]KeyRecord, error) {
+	mks.mu.RLock()
+	defer mks.mu.RUnlock()
+
+	records := make([]KeyRecord, 0, len(mks.records))
+	for _, record := range mks.records {
+		records = append(records, copyRecord(record))
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records, nil
+}
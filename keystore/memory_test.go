@@ -0,0 +1,220 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"grpc-app-auth/signing"
+)
+
+// TestMemoryKeyStoreConcurrentAccess is meant to be run with -race.
+func TestMemoryKeyStoreConcurrentAccess(t *testing.T) {
+	const (
+		writers = 8
+		readers = 32
+		keys    = 200
+	)
+
+	mks := NewMemoryKeyStore()
+
+	// Every writer stores the same keys, so readers always see the same bytes.
+	publicKeys := make([][]byte, keys)
+	keyIDs := make([]string, keys)
+	for i := range publicKeys {
+		publicKeys[i] = testKey(fmt.Sprintf("key-%d", i))
+		keyIDs[i] = signing.Fingerprint(signing.Ed25519, publicKeys[i])
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				if err := mks.StorePublicKey(keyIDs[i], publicKeys[i]); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				key, err := mks.GetPublicKey(keyIDs[i])
+				if err == nil && string(key) != string(publicKeys[i]) {
+					t.Errorf("unexpected key %v for key-%d", key, i)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	for i := 0; i < keys; i++ {
+		if _, err := mks.GetPublicKey(keyIDs[i]); err != nil {
+			t.Fatalf("key-%d missing after writes: %v", i, err)
+		}
+	}
+}
+
+func TestMemoryKeyStoreCopiesKeys(t *testing.T) {
+	mks := NewMemoryKeyStore()
+
+	key := testKey("key")
+	keyID := signing.Fingerprint(signing.Ed25519, key)
+	want := append([]byte(nil), key...)
+	groups := []string{"ops"}
+	if err := mks.StoreKeyRecord(KeyRecord{ID: keyID, PublicKey: key, Groups: groups}); err != nil {
+		t.Fatal(err)
+	}
+	key[0]++
+	groups[0] = "admins"
+
+	// Neither the caller's slices nor the ones returned alias the store.
+	record, err := mks.GetKeyRecord(keyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.PublicKey[0]++
+	record.Groups[0] = "admins"
+	listed, err := mks.ListPublicKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed[0].PublicKey[0]++
+	listed[0].Groups[0] = "admins"
+
+	stored, err := mks.GetKeyRecord(keyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored.PublicKey, want) || stored.Groups[0] != "ops" {
+		t.Fatalf("stored record changed with a caller's slice: %+v", stored)
+	}
+}
+
+func TestMemoryKeyStoreValidity(t *testing.T) {
+	mks := NewMemoryKeyStore()
+	now := time.Now()
+
+	ids := map[string]string{}
+	for _, name := range []string{"valid", "expired", "future", "revoked", "unknown"} {
+		ids[name] = signing.Fingerprint(signing.Ed25519, testKey(name))
+	}
+
+	records := []KeyRecord{
+		{ID: ids["valid"], PublicKey: testKey("valid"), NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)},
+		{ID: ids["expired"], PublicKey: testKey("expired"), NotAfter: now.Add(-time.Hour)},
+		{ID: ids["future"], PublicKey: testKey("future"), NotBefore: now.Add(time.Hour)},
+		{ID: ids["revoked"], PublicKey: testKey("revoked")},
+	}
+	for _, record := range records {
+		if err := mks.StoreKeyRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mks.RevokePublicKey(ids["revoked"]); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]error{
+		"valid":   nil,
+		"expired": ErrKeyExpired,
+		"future":  ErrKeyNotYetValid,
+		"revoked": ErrKeyRevoked,
+		"unknown": ErrKeyNotFound,
+	} {
+		if _, err := mks.GetPublicKey(ids[name]); !errors.Is(err, want) {
+			t.Errorf("%s: expected %v, got %v", name, want, err)
+		}
+	}
+
+	if err := mks.DeletePublicKey(ids["revoked"]); err != nil {
+		t.Fatal(err)
+	}
+	listed, err := mks.ListPublicKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 3 {
+		t.Fatalf("unexpected records %+v", listed)
+	}
+
+	if err := mks.StorePublicKey("valid", testKey("valid")); !errors.Is(err, ErrInvalidRecord) {
+		t.Fatalf("expected a key ID that is not the fingerprint to be rejected, got %v", err)
+	}
+	malformed := []byte("not a key")
+	if err := mks.StorePublicKey(signing.Fingerprint(signing.Ed25519, malformed), malformed); !errors.Is(err, ErrInvalidRecord) {
+		t.Fatalf("expected a malformed key to be rejected, got %v", err)
+	}
+}
+
+func TestMemoryKeyStoreUpdateKeyRecord(t *testing.T) {
+	mks := NewMemoryKeyStore()
+
+	key := testKey("key")
+	keyID := signing.Fingerprint(signing.Ed25519, key)
+	create := func(record KeyRecord, ok bool) (KeyRecord, error) {
+		if ok {
+			return KeyRecord{}, ErrKeyExists
+		}
+		return KeyRecord{ID: keyID, PublicKey: key, Pending: true}, nil
+	}
+	if err := mks.UpdateKeyRecord(keyID, create); err != nil {
+		t.Fatal(err)
+	}
+	if err := mks.UpdateKeyRecord(keyID, create); !errors.Is(err, ErrKeyExists) {
+		t.Fatalf("expected the error from fn, got %v", err)
+	}
+
+	// Approvals racing with a revocation must not undo it.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := mks.UpdateKeyRecord(keyID, func(record KeyRecord, ok bool) (KeyRecord, error) {
+				if record.Revoked {
+					return KeyRecord{}, ErrKeyRevoked
+				}
+				record.Pending = false
+				return record, nil
+			})
+			if err != nil && !errors.Is(err, ErrKeyRevoked) {
+				t.Error(err)
+			}
+		}()
+	}
+	if err := mks.RevokePublicKey(keyID); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if _, err := mks.GetPublicKey(keyID); !errors.Is(err, ErrKeyRevoked) {
+		t.Fatalf("expected the key to stay revoked, got %v", err)
+	}
+
+	other := signing.Fingerprint(signing.Ed25519, testKey("other"))
+	err := mks.UpdateKeyRecord(keyID, func(record KeyRecord, ok bool) (KeyRecord, error) {
+		return KeyRecord{ID: other, PublicKey: testKey("other")}, nil
+	})
+	if !errors.Is(err, ErrInvalidRecord) {
+		t.Fatalf("expected changing the key ID to be rejected, got %v", err)
+	}
+}
+
+// testKey returns an ed25519 public key derived from name.
+func testKey(name string) []byte {
+	seed := sha256.Sum256([]byte(name))
+	return ed25519.NewKeyFromSeed(seed[:]).Public().(ed25519.PublicKey)
+}
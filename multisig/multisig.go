@@ -0,0 +1,163 @@
+// Package multisig collects signatures from several keys over a single
+// request, so that a server can require an M-of-N quorum for sensitive
+// methods.
+//
+// An Envelope holds the method, timestamp, nonce and deterministic encoding
+// of the request, plus one signature per key over its multisig encoding (see
+// canonical.EncodeMultisig), which no single-key request shares. Envelopes are passed between
+// signers as base64url encoded JSON, so co-signatures can be collected
+// offline: the initiator creates the envelope, each co-signer inspects the
+// request with Unmarshal and adds a signature, and whoever holds the final
+// envelope sends it with the request.
+package multisig
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"grpc-app-auth/canonical"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// MetadataKey carries the envelope, without the request message, alongside
+// the request it signs.
+const MetadataKey = "multisig"
+
+const nonceSize = 16
+
+var ErrMalformedEnvelope = errors.New("malformed multi-signature envelope")
+
+// Envelope is a request and the signatures collected over it.
+type Envelope struct {
+	// FullMethod is the gRPC method in "/package.Service/Method" form.
+	FullMethod string `json:"method"`
+	Timestamp  int64  `json:"ts"`
+	Nonce      string `json:"nonce"`
+	// Message is the deterministic protobuf encoding of the request. It is
+	// left out when the envelope is sent with the request itself.
+	Message    []byte      `json:"msg,omitempty"`
+	Signatures []Signature `json:"sigs"`
+}
+
+// Signature is one key's signature over the envelope's request.
+type Signature struct {
+	KeyID     string `json:"kid"`
+	Signature []byte `json:"sig"`
+}
+
+// NewEnvelope starts collecting signatures for a call to fullMethod with req,
+// timestamped now. The server only accepts it within its multi-signature
+// window of that time.
+func NewEnvelope(fullMethod string, req proto.Message) (*Envelope, error) {
+	if _, _, err := canonical.SplitMethod(fullMethod); err != nil {
+		return nil, err
+	}
+
+	message, err := canonical.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		FullMethod: fullMethod,
+		Timestamp:  time.Now().Unix(),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Message:    message,
+	}, nil
+}
+
+// Parse decodes an envelope produced by Marshal.
+func Parse(envelope string) (*Envelope, error) {
+	data, err := base64.RawURLEncoding.DecodeString(envelope)
+	if err != nil {
+		return nil, ErrMalformedEnvelope
+	}
+
+	var e Envelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedEnvelope, err)
+	}
+	if _, _, err := canonical.SplitMethod(e.FullMethod); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedEnvelope, err)
+	}
+	return &e, nil
+}
+
+// Marshal encodes the envelope for handing to the next signer.
+func (e *Envelope) Marshal() (string, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Unmarshal decodes the request into msg, so a co-signer can check what it
+// is asked to sign.
+func (e *Envelope) Unmarshal(msg proto.Message) error {
+	return proto.Unmarshal(e.Message, msg)
+}
+
+// Payload returns the canonical multisig encoding every signature covers.
+func (e *Envelope) Payload() ([]byte, error) {
+	return canonical.EncodeMultisig(e.FullMethod, e.Timestamp, e.Nonce, e.Message)
+}
+
+// Sign adds privateKey's signature under keyID, replacing any earlier
+// signature by the same key.
+func (e *Envelope) Sign(keyID string, privateKey crypto.Signer) error {
+	payload, err := e.Payload()
+	if err != nil {
+		return err
+	}
+
+	signature, _, err := signing.Sign(privateKey, payload)
+	if err != nil {
+		return err
+	}
+
+	for i := range e.Signatures {
+		if e.Signatures[i].KeyID == keyID {
+			e.Signatures[i].Signature = signature
+			return nil
+		}
+	}
+	e.Signatures = append(e.Signatures, Signature{KeyID: keyID, Signature: signature})
+	return nil
+}
+
+// Signers returns the IDs of the keys that have signed, in signing order.
+func (e *Envelope) Signers() []string {
+	keyIDs := make([]string, 0, len(e.Signatures))
+	for _, s := range e.Signatures {
+		keyIDs = append(keyIDs, s.KeyID)
+	}
+	return keyIDs
+}
+
+// AppendToOutgoingContext attaches the envelope to a call made with ctx. The
+// call must be to the envelope's method with the request it was created for.
+func (e *Envelope) AppendToOutgoingContext(ctx context.Context) (context.Context, error) {
+	header := *e
+	header.Message = nil
+
+	encoded, err := header.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return metadata.AppendToOutgoingContext(ctx, MetadataKey, encoded), nil
+}
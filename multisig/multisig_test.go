@@ -0,0 +1,91 @@
+package multisig
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	"grpc-app-auth/canonical"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+)
+
+func TestEnvelope(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := signing.Fingerprint(signing.Ed25519, publicKey)
+
+	req := &pb.RevokeKeyRequest{KeyId: "ed25519:uAAAA"}
+	e, err := NewEnvelope("/services.KeyAdmin/RevokeKey", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Signatures cover the multisig encoding, never a single-key request's.
+	payload, err := e.Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := canonical.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := canonical.EncodeMultisig(e.FullMethod, e.Timestamp, e.Nonce, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(payload, expected) {
+		t.Fatal("envelope payload differs from the canonical multisig encoding")
+	}
+	single, err := canonical.EncodeRequest(canonical.Request{
+		FullMethod: e.FullMethod,
+		Timestamp:  e.Timestamp,
+		Nonce:      e.Nonce,
+		Message:    req,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(payload, single) {
+		t.Fatal("envelope payload must differ from the single-key request encoding")
+	}
+
+	if err := e.Sign(keyID, privateKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Sign(keyID, privateKey); err != nil {
+		t.Fatal(err)
+	}
+	if signers := e.Signers(); len(signers) != 1 || signers[0] != keyID {
+		t.Fatalf("unexpected signers %v", signers)
+	}
+
+	encoded, err := e.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded pb.RevokeKeyRequest
+	if err := parsed.Unmarshal(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.KeyId != req.KeyId {
+		t.Fatalf("unexpected request %v", &decoded)
+	}
+	if err := signing.Verify(signing.Ed25519, publicKey, payload, parsed.Signatures[0].Signature); err != nil {
+		t.Fatalf("signature did not survive encoding: %v", err)
+	}
+
+	if _, err := Parse("not an envelope"); !errors.Is(err, ErrMalformedEnvelope) {
+		t.Fatalf("expected malformed envelope, got %v", err)
+	}
+	if _, err := NewEnvelope("Echo", req); err == nil {
+		t.Fatal("expected an invalid method to be rejected")
+	}
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"grpc-app-auth/canonical"
+	"grpc-app-auth/internal/challenge"
+	"grpc-app-auth/keystore"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DefaultChallengeTTL = time.Minute
+
+	// MaxPendingEnrollments caps the keys waiting for approval, since anyone
+	// can enroll one.
+	MaxPendingEnrollments = 1000
+
+	maxOutstandingChallenges = 10000
+)
+
+// EnrollmentPolicy reports whether an enrollment is approved immediately
+// instead of waiting for an admin. It is only called once the client has
+// proven possession of its key.
+type EnrollmentPolicy func(ctx context.Context, req *pb.EnrollRequest) bool
+
+// EnrollmentTokenPolicy approves enrollments presenting the pre-shared token.
+func EnrollmentTokenPolicy(token string) EnrollmentPolicy {
+	return func(ctx context.Context, req *pb.EnrollRequest) bool {
+		return token != "" && subtle.ConstantTimeCompare([]byte(req.EnrollmentToken), []byte(token)) == 1
+	}
+}
+
+// GetChallenge issues a single-use challenge for Enroll.
+func (s *Server) GetChallenge(ctx context.Context, in *pb.ChallengeRequest) (*pb.ChallengeReply, error) {
+	return issueChallenge(s.enrollmentChallenges)
+}
+
+// issueChallenge issues a challenge from store. Each service has its own
+// store, so clients flooding one cannot lock others out.
+func issueChallenge(store *challenge.Store) (*pb.ChallengeReply, error) {
+	ch, expiry, err := store.Issue()
+	if errors.Is(err, challenge.ErrStoreFull) {
+		return nil, status.Errorf(codes.ResourceExhausted, "too many outstanding challenges")
+	} else if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not issue challenge")
+	}
+
+	return &pb.ChallengeReply{Challenge: ch, ExpiresAt: expiry.Unix()}, nil
+}
+
+// Enroll stores the client's key once it proves possession of the matching
+// private key. The key stays pending until approved by an admin unless the
+// enrollment policy approves it. Keys that would be pending are rejected with
+// ResourceExhausted once MaxPendingEnrollments are waiting.
+func (s *Server) Enroll(ctx context.Context, in *pb.EnrollRequest) (*pb.EnrollReply, error) {
+	alg, err := s.checkPublicKey(in.Algorithm, in.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.enrollmentChallenges.Consume(in.Challenge) {
+		return nil, status.Errorf(codes.Unauthenticated, "challenge is unknown or expired")
+	}
+
+	if signing.Verify(alg, in.PublicKey, canonical.EncodeEnrollment(in.Challenge, in.PublicKey), in.Signature) != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "signature is not valid")
+	}
+
+	keyID := signing.Fingerprint(alg, in.PublicKey)
+
+	s.enrollMu.Lock()
+	defer s.enrollMu.Unlock()
+
+	// The label is chosen by the client, so it is only a request until an
+	// admin approves the key; authorization rules never see it before then.
+	approved := s.enrollmentPolicy != nil && s.enrollmentPolicy(ctx, in)
+	record := keystore.KeyRecord{
+		ID:             keyID,
+		PublicKey:      in.PublicKey,
+		Algorithm:      alg,
+		Pending:        !approved,
+		RequestedLabel: in.Label,
+	}
+	if record.Pending {
+		pending, err := s.pendingKeys()
+		if err != nil {
+			return nil, keyStoreError(err)
+		}
+		if pending >= MaxPendingEnrollments {
+			return nil, status.Errorf(codes.ResourceExhausted, "too many keys are waiting for approval")
+		}
+	}
+	err = s.trustedKeys.UpdateKeyRecord(keyID, func(_ keystore.KeyRecord, ok bool) (keystore.KeyRecord, error) {
+		if ok {
+			return keystore.KeyRecord{}, fmt.Errorf("%s: %w", keyID, keystore.ErrKeyExists)
+		}
+		return record, nil
+	})
+	if errors.Is(err, keystore.ErrKeyExists) {
+		return nil, status.Errorf(codes.AlreadyExists, "key %s is already enrolled", keyID)
+	} else if err != nil {
+		return nil, keyStoreError(err)
+	}
+
+	log.Printf("[server] Enrolled key %s (approved: %v, requested label %q)", keyID, approved, in.Label)
+	return &pb.EnrollReply{KeyId: keyID, Approved: approved}, nil
+}
+
+func (s *Server) pendingKeys() (int, error) {
+	records, err := s.trustedKeys.ListPublicKeys()
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, record := range records {
+		if record.Pending {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// checkPublicKey returns the algorithm of a key submitted by a client,
+// rejecting malformed keys and algorithms the verifier does not allow.
+func (s *Server) checkPublicKey(algorithm string, publicKey []byte) (signing.Algorithm, error) {
+	alg, err := signing.ParseAlgorithm(algorithm)
+	if err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	if !s.verifier.AllowsAlgorithm(alg) {
+		return "", status.Errorf(codes.InvalidArgument, "algorithm %s is not allowed", alg)
+	}
+	if _, err := signing.ParsePublicKey(alg, publicKey); err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "invalid public key: %v", err)
+	}
+	return alg, nil
+}
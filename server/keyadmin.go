@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"grpc-app-auth/auth"
+	"grpc-app-auth/keystore"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RegisterKey trusts a new client key. The KeyAdmin methods are only served
+// when admin keys are configured with WithAdminKeys.
+func (s *Server) RegisterKey(ctx context.Context, in *pb.RegisterKeyRequest) (*pb.KeyInfo, error) {
+	alg, err := s.checkPublicKey(in.Algorithm, in.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keyID := signing.Fingerprint(alg, in.PublicKey)
+	record := keystore.KeyRecord{
+		ID:        keyID,
+		PublicKey: in.PublicKey,
+		Algorithm: alg,
+		Label:     in.Label,
+		Groups:    in.Groups,
+		NotBefore: fromUnix(in.NotBefore),
+		NotAfter:  fromUnix(in.NotAfter),
+	}
+
+	s.enrollMu.Lock()
+	defer s.enrollMu.Unlock()
+
+	err = s.trustedKeys.UpdateKeyRecord(keyID, func(_ keystore.KeyRecord, ok bool) (keystore.KeyRecord, error) {
+		if ok {
+			return keystore.KeyRecord{}, fmt.Errorf("%s: %w", keyID, keystore.ErrKeyExists)
+		}
+		return record, nil
+	})
+	if errors.Is(err, keystore.ErrKeyExists) {
+		return nil, status.Errorf(codes.AlreadyExists, "key %s is already registered", keyID)
+	} else if err != nil {
+		return nil, keyStoreError(err)
+	}
+
+	logAdminAction(ctx, "registered", keyID)
+	return keyInfo(record), nil
+}
+
+func (s *Server) RevokeKey(ctx context.Context, in *pb.RevokeKeyRequest) (*pb.KeyInfo, error) {
+	if err := s.trustedKeys.RevokePublicKey(in.KeyId); err != nil {
+		return nil, keyStoreError(err)
+	}
+
+	record, err := s.trustedKeys.GetKeyRecord(in.KeyId)
+	if err != nil {
+		return nil, keyStoreError(err)
+	}
+
+	logAdminAction(ctx, "revoked", in.KeyId)
+	return keyInfo(record), nil
+}
+
+func (s *Server) ListKeys(ctx context.Context, in *pb.ListKeysRequest) (*pb.ListKeysReply, error) {
+	records, err := s.trustedKeys.ListPublicKeys()
+	if err != nil {
+		return nil, keyStoreError(err)
+	}
+
+	reply := &pb.ListKeysReply{Keys: make([]*pb.KeyInfo, 0, len(records))}
+	for _, record := range records {
+		reply.Keys = append(reply.Keys, keyInfo(record))
+	}
+	return reply, nil
+}
+
+func (s *Server) GetKey(ctx context.Context, in *pb.GetKeyRequest) (*pb.KeyInfo, error) {
+	record, err := s.trustedKeys.GetKeyRecord(in.KeyId)
+	if err != nil {
+		return nil, keyStoreError(err)
+	}
+	return keyInfo(record), nil
+}
+
+// ApproveKey trusts a key that is pending after enrollment and gives it the
+// label it asked for. Keys approved by the enrollment policy have no label
+// until an admin approves them too.
+func (s *Server) ApproveKey(ctx context.Context, in *pb.ApproveKeyRequest) (*pb.KeyInfo, error) {
+	var approved keystore.KeyRecord
+	err := s.trustedKeys.UpdateKeyRecord(in.KeyId, func(record keystore.KeyRecord, ok bool) (keystore.KeyRecord, error) {
+		switch {
+		case !ok:
+			return keystore.KeyRecord{}, fmt.Errorf("%s: %w", in.KeyId, keystore.ErrKeyNotFound)
+		case record.Revoked:
+			return keystore.KeyRecord{}, fmt.Errorf("%s: %w", in.KeyId, keystore.ErrKeyRevoked)
+		}
+
+		record.Pending = false
+		if record.RequestedLabel != "" {
+			record.Label, record.RequestedLabel = record.RequestedLabel, ""
+		}
+		approved = record
+		return record, nil
+	})
+	if errors.Is(err, keystore.ErrKeyRevoked) {
+		return nil, status.Errorf(codes.FailedPrecondition, "key %s has been revoked", in.KeyId)
+	} else if err != nil {
+		return nil, keyStoreError(err)
+	}
+
+	logAdminAction(ctx, "approved", in.KeyId)
+	return keyInfo(approved), nil
+}
+
+func keyInfo(record keystore.KeyRecord) *pb.KeyInfo {
+	alg, _ := signing.ParseAlgorithm(string(record.Algorithm))
+	return &pb.KeyInfo{
+		KeyId:     record.ID,
+		PublicKey: record.PublicKey,
+		Label:     record.Label,
+		NotBefore: toUnix(record.NotBefore),
+		NotAfter:  toUnix(record.NotAfter),
+		Revoked:   record.Revoked,
+		Pending:   record.Pending,
+		Groups:    record.Groups,
+		Algorithm: string(alg),
+	}
+}
+
+func keyStoreError(err error) error {
+	if errors.Is(err, keystore.ErrKeyNotFound) {
+		return status.Errorf(codes.NotFound, "%v", err)
+	}
+	if errors.Is(err, keystore.ErrInvalidRecord) {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	return status.Errorf(codes.Internal, "key store: %v", err)
+}
+
+func logAdminAction(ctx context.Context, action string, keyID string) {
+	adminKeyID, _ := auth.KeyIDFromContext(ctx)
+	log.Printf("[server] Admin %s %s key %s", adminKeyID, action, keyID)
+}
+
+func fromUnix(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
+func toUnix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"grpc-app-auth/auth"
+	"grpc-app-auth/canonical"
+	"grpc-app-auth/keystore"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRotationGracePeriod is how long a rotated key stays valid by default.
+const DefaultRotationGracePeriod = 24 * time.Hour
+
+// GetRotationChallenge issues a single-use challenge for RotateKey.
+func (s *Server) GetRotationChallenge(ctx context.Context, in *pb.ChallengeRequest) (*pb.ChallengeReply, error) {
+	return issueChallenge(s.rotationChallenges)
+}
+
+// RotateKey trusts a new key in place of a currently valid one. The new key
+// inherits the old key's label and groups, and the old key keeps working for
+// the rotation grace period so in-flight clients can switch over.
+func (s *Server) RotateKey(ctx context.Context, in *pb.RotateKeyRequest) (*pb.RotateKeyReply, error) {
+	alg, err := s.checkPublicKey(in.NewAlgorithm, in.NewPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.rotationChallenges.Consume(in.Challenge) {
+		return nil, status.Errorf(codes.Unauthenticated, "challenge is unknown or expired")
+	}
+
+	old, err := s.trustedKeys.GetKeyRecord(in.KeyId)
+	if err == nil {
+		err = old.Validate(time.Now())
+	}
+	if err != nil {
+		return nil, auth.KeyError(err)
+	}
+
+	if !s.verifier.AllowsAlgorithm(old.Algorithm) {
+		return nil, status.Errorf(codes.Unauthenticated, "key algorithm is not allowed")
+	}
+
+	statement := canonical.EncodeRotation(in.Challenge, in.KeyId, in.NewAlgorithm, in.NewPublicKey)
+	if signing.Verify(old.Algorithm, old.PublicKey, statement, in.Endorsement) != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "endorsement is not valid")
+	}
+	if signing.Verify(alg, in.NewPublicKey, statement, in.Proof) != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "proof of possession is not valid")
+	}
+
+	next := keystore.KeyRecord{
+		ID:        signing.Fingerprint(alg, in.NewPublicKey),
+		PublicKey: in.NewPublicKey,
+		Algorithm: alg,
+		Label:     old.Label,
+		Groups:    old.Groups,
+		// Rotating must not extend the identity past the old key's validity.
+		NotBefore:      old.NotBefore,
+		NotAfter:       old.NotAfter,
+		RequestedLabel: old.RequestedLabel,
+	}
+	oldNotAfter := time.Now().Add(s.rotationGrace)
+	err = s.trustedKeys.RotateKey(in.KeyId, next, oldNotAfter)
+	if errors.Is(err, keystore.ErrKeyExists) {
+		return nil, status.Errorf(codes.AlreadyExists, "key %s is already trusted", next.ID)
+	} else if err != nil {
+		// The old key may have been revoked or removed since it was checked.
+		if keyErr := auth.KeyError(err); status.Code(keyErr) == codes.Unauthenticated {
+			return nil, keyErr
+		}
+		return nil, keyStoreError(err)
+	}
+
+	record, err := s.trustedKeys.GetKeyRecord(in.KeyId)
+	if err != nil {
+		return nil, keyStoreError(err)
+	}
+
+	log.Printf("[server] Rotated key %s to %s (old key valid until %v)", in.KeyId, next.ID, record.NotAfter)
+	return &pb.RotateKeyReply{NewKeyId: next.ID, OldKeyExpiresAt: toUnix(record.NotAfter)}, nil
+}
@@ -3,16 +3,26 @@ package server
 import (
 	"context"
 	"crypto/ed25519"
-	"encoding/base64"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"grpc-app-auth/auth"
+	"grpc-app-auth/canonical"
 	pb "grpc-app-auth/services"
-	"grpc-app-auth/utils"
+	"grpc-app-auth/signing"
+	"grpc-app-auth/tlsutil"
 
-	"grpc-app-auth/internal/keystore"
+	"grpc-app-auth/internal/challenge"
+	"grpc-app-auth/keystore"
+	"grpc-app-auth/multisig"
+	"grpc-app-auth/session"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
@@ -23,25 +33,133 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/status"
 )
 
 type Server struct {
 	pb.UnimplementedEchoServer
 	pb.UnimplementedAddServer
-	trustedKeys    keystore.KeyStore
-	grpcServer     *grpc.Server
-	tracerProvider *sdktrace.TracerProvider
+	pb.UnimplementedKeyAdminServer
+	pb.UnimplementedEnrollmentServer
+	pb.UnimplementedSessionServer
+	pb.UnimplementedKeyRotationServer
+	trustedKeys          keystore.KeyStore
+	adminKeys            keystore.KeyStore
+	enrollment           bool
+	enrollmentPolicy     EnrollmentPolicy
+	enrollmentChallenges *challenge.Store
+	loginChallenges      *challenge.Store
+	rotationChallenges   *challenge.Store
+	sessions             *session.Issuer
+	rotation             bool
+	rotationGrace        time.Duration
+	responseSigner       *auth.ResponseSigner
+	tlsConfig            *tls.Config
+	tracingTLS           *tls.Config
+	network              string
+	address              string
+	socketMode           os.FileMode
+	drainTimeout         time.Duration
+	verifier             *auth.Verifier
+	tracerProvider       *sdktrace.TracerProvider
+	tracingShutdown      sync.Once
+
+	// enrollMu serializes adding keys, so counting pending keys and storing
+	// a new one is atomic.
+	enrollMu sync.Mutex
+
+	// mu guards grpcServer, addr and stopped, which Serve and Stop may touch
+	// from different goroutines. ready is closed once the server is listening.
+	mu         sync.Mutex
+	grpcServer *grpc.Server
+	addr       net.Addr
+	stopped    bool
+	ready      chan struct{}
 }
 
+// ErrServerStopped is returned by Serve and ServeListener when the server has
+// already been stopped.
+var ErrServerStopped = errors.New("server stopped")
+
 type ServerOption func(*serverOptions) error
 
 type serverOptions struct {
-	enableTracing bool
-	tracingTarget string
+	enableTracing    bool
+	tracingTarget    string
+	tracingTLS       *tls.Config
+	verifierOpts     []auth.VerifierOption
+	adminKeys        keystore.KeyStore
+	enrollment       bool
+	enrollmentPolicy EnrollmentPolicy
+	sessions         *session.Issuer
+	rotation         bool
+	rotationGrace    time.Duration
+	responseSigner   *auth.ResponseSigner
+	tlsConfig        *tls.Config
+	network          string
+	address          string
+	socketMode       os.FileMode
+	drainTimeout     time.Duration
+}
+
+// DefaultAddress is the TCP address Serve listens on unless WithAddress or
+// WithUnixSocket is given.
+const DefaultAddress = ":50051"
+
+// DefaultSocketMode lets only the server's user connect to a Unix socket.
+const DefaultSocketMode os.FileMode = 0600
+
+// DefaultDrainTimeout is how long Run waits for in-flight RPCs to finish
+// during shutdown unless WithDrainTimeout is given.
+const DefaultDrainTimeout = 10 * time.Second
+
+// WithDrainTimeout sets how long Run waits for in-flight RPCs to finish once
+// its context is done before closing them.
+func WithDrainTimeout(timeout time.Duration) ServerOption {
+	return func(o *serverOptions) error {
+		if timeout <= 0 {
+			return fmt.Errorf("drain timeout must be positive")
+		}
+		o.drainTimeout = timeout
+		return nil
+	}
+}
+
+// WithAddress listens on a TCP address such as "localhost:50051".
+func WithAddress(address string) ServerOption {
+	return func(o *serverOptions) error {
+		if address == "" {
+			return fmt.Errorf("listen address must not be empty")
+		}
+		o.network = "tcp"
+		o.address = address
+		return nil
+	}
+}
+
+// WithUnixSocket listens on a Unix domain socket at path whose permissions
+// are set to mode, or DefaultSocketMode if mode is 0. A socket left at path by
+// an earlier server is replaced, but Serve fails if a server still accepts
+// connections on it. path must be short enough for its directory to hold
+// the temporary socket Serve creates first, well under 104 bytes.
+func WithUnixSocket(path string, mode os.FileMode) ServerOption {
+	return func(o *serverOptions) error {
+		if path == "" {
+			return fmt.Errorf("socket path must not be empty")
+		}
+		if mode&^os.ModePerm != 0 {
+			return fmt.Errorf("socket mode must only hold permission bits")
+		}
+		if mode == 0 {
+			mode = DefaultSocketMode
+		}
+		o.network = "unix"
+		o.address = path
+		o.socketMode = mode
+		return nil
+	}
 }
 
 func WithOpenTelemetry(target string) ServerOption {
@@ -52,15 +170,247 @@ func WithOpenTelemetry(target string) ServerOption {
 	}
 }
 
+// WithOpenTelemetryTLS connects to the trace collector over TLS with config,
+// such as one from tlsutil.ClientConfig, instead of in plaintext.
+func WithOpenTelemetryTLS(config *tls.Config) ServerOption {
+	return func(o *serverOptions) error {
+		if config == nil {
+			return fmt.Errorf("collector TLS config must not be nil")
+		}
+		o.tracingTLS = config
+		return nil
+	}
+}
+
+// WithTLS serves over TLS with the PEM encoded certificate and key in certFile
+// and keyFile.
+func WithTLS(certFile, keyFile string) ServerOption {
+	return func(o *serverOptions) error {
+		config, err := tlsutil.ServerConfig(certFile, keyFile, "")
+		if err != nil {
+			return err
+		}
+		o.tlsConfig = config
+		return nil
+	}
+}
+
+// WithMutualTLS serves over TLS like WithTLS and requires clients to present a
+// certificate issued by a CA in clientCAFile.
+func WithMutualTLS(certFile, keyFile, clientCAFile string) ServerOption {
+	return func(o *serverOptions) error {
+		config, err := tlsutil.ServerConfig(certFile, keyFile, clientCAFile)
+		if err != nil {
+			return err
+		}
+		o.tlsConfig = config
+		return nil
+	}
+}
+
+// WithSelfSignedTLS serves over TLS with a self-signed certificate derived
+// from privateKey, typically the key given to WithIdentity, for clients that
+// pin its public key with tlsutil.PinnedClientConfig.
+func WithSelfSignedTLS(privateKey ed25519.PrivateKey, hosts ...string) ServerOption {
+	return func(o *serverOptions) error {
+		if len(privateKey) != ed25519.PrivateKeySize {
+			return fmt.Errorf("TLS key must be an ed25519 private key")
+		}
+		config, err := tlsutil.SelfSignedServerConfig(privateKey, hosts...)
+		if err != nil {
+			return err
+		}
+		o.tlsConfig = config
+		return nil
+	}
+}
+
+// WithTLSConfig serves over TLS with config.
+func WithTLSConfig(config *tls.Config) ServerOption {
+	return func(o *serverOptions) error {
+		if config == nil {
+			return fmt.Errorf("TLS config must not be nil")
+		}
+		o.tlsConfig = config
+		return nil
+	}
+}
+
+// WithChannelBinding rejects signed requests received over TLS unless their
+// signature is bound to the TLS session, so a signed request cannot be
+// relayed over another connection. Multi-signature envelopes cannot be bound,
+// so methods given a quorum with WithQuorum are refused over TLS.
+func WithChannelBinding() ServerOption {
+	return func(o *serverOptions) error {
+		o.verifierOpts = append(o.verifierOpts, auth.WithChannelBinding())
+		return nil
+	}
+}
+
+// WithReplayWindow sets how far a request timestamp may drift from the server
+// clock before the request is rejected.
+func WithReplayWindow(window time.Duration) ServerOption {
+	return func(o *serverOptions) error {
+		o.verifierOpts = append(o.verifierOpts, auth.WithReplayWindow(window))
+		return nil
+	}
+}
+
+// WithNonceCacheSize bounds the number of nonces remembered for replay protection.
+func WithNonceCacheSize(size int) ServerOption {
+	return func(o *serverOptions) error {
+		o.verifierOpts = append(o.verifierOpts, auth.WithNonceCacheSize(size))
+		return nil
+	}
+}
+
+// WithAllowedAlgorithms restricts the signature algorithms client keys may
+// use, both for signing requests and when registering or enrolling keys.
+func WithAllowedAlgorithms(algorithms ...signing.Algorithm) ServerOption {
+	return func(o *serverOptions) error {
+		o.verifierOpts = append(o.verifierOpts, auth.WithAllowedAlgorithms(algorithms...))
+		return nil
+	}
+}
+
+// WithAdminKeys serves the KeyAdmin service, accepting only calls signed by
+// keys in adminKeys. The trusted keys cannot call KeyAdmin.
+func WithAdminKeys(adminKeys keystore.KeyStore) ServerOption {
+	return func(o *serverOptions) error {
+		if adminKeys == nil {
+			return fmt.Errorf("admin key store must not be nil")
+		}
+		o.adminKeys = adminKeys
+		o.verifierOpts = append(o.verifierOpts, auth.WithServiceKeyStore("services.KeyAdmin", adminKeys))
+		return nil
+	}
+}
+
+// WithEnrollment serves the Enrollment service so clients can ask for their
+// keys to be trusted. Enrolled keys are pending until approved through
+// KeyAdmin unless policy approves them; policy may be nil.
+func WithEnrollment(policy EnrollmentPolicy) ServerOption {
+	return func(o *serverOptions) error {
+		o.enrollment = true
+		o.enrollmentPolicy = policy
+		o.verifierOpts = append(o.verifierOpts, auth.WithUnauthenticatedService("services.Enrollment"))
+		return nil
+	}
+}
+
+// WithSessions serves the Session service, issuing tokens that are valid for
+// ttl and signed by signingKey. Requests may then carry a session token
+// instead of a signature. A random signing key is generated if signingKey is
+// nil, which invalidates all tokens when the server restarts.
+func WithSessions(signingKey ed25519.PrivateKey, ttl time.Duration) ServerOption {
+	return func(o *serverOptions) error {
+		if ttl <= 0 {
+			return fmt.Errorf("session ttl must be positive")
+		}
+		if signingKey == nil {
+			var err error
+			if _, signingKey, err = ed25519.GenerateKey(nil); err != nil {
+				return err
+			}
+		} else if len(signingKey) != ed25519.PrivateKeySize {
+			return fmt.Errorf("session signing key must be an ed25519 private key")
+		}
+		o.sessions = session.NewIssuer(signingKey, ttl)
+		o.verifierOpts = append(o.verifierOpts,
+			auth.WithUnauthenticatedService("services.Session"),
+			auth.WithSessionKey(o.sessions.PublicKey()),
+		)
+		return nil
+	}
+}
+
+// WithKeyRotation serves the KeyRotation service so clients can replace their
+// key with a new one. The replaced key stays valid for gracePeriod, or
+// DefaultRotationGracePeriod if gracePeriod is 0.
+func WithKeyRotation(gracePeriod time.Duration) ServerOption {
+	return func(o *serverOptions) error {
+		if gracePeriod < 0 {
+			return fmt.Errorf("rotation grace period must not be negative")
+		}
+		if gracePeriod == 0 {
+			gracePeriod = DefaultRotationGracePeriod
+		}
+		o.rotation = true
+		o.rotationGrace = gracePeriod
+		o.verifierOpts = append(o.verifierOpts, auth.WithUnauthenticatedService("services.KeyRotation"))
+		return nil
+	}
+}
+
+// WithIdentity signs every successful unary response with the server's
+// identity key, bound to the request, so clients that pin the public key with
+// auth.NewResponseVerifier can detect tampering.
+func WithIdentity(privateKey ed25519.PrivateKey) ServerOption {
+	return func(o *serverOptions) error {
+		if len(privateKey) != ed25519.PrivateKeySize {
+			return fmt.Errorf("identity key must be an ed25519 private key")
+		}
+		o.responseSigner = auth.NewResponseSigner(privateKey)
+		return nil
+	}
+}
+
+// WithQuorum requires calls to fullMethod to be approved by threshold
+// distinct keys in group, collected in a multisig.Envelope. A method ending in
+// '*' covers every method with that prefix, e.g. "/services.KeyAdmin/*".
+func WithQuorum(fullMethod string, threshold int, group string) ServerOption {
+	return func(o *serverOptions) error {
+		o.verifierOpts = append(o.verifierOpts, auth.WithQuorum(fullMethod, threshold, group))
+		return nil
+	}
+}
+
+// WithMultiSignatureWindow sets how long co-signers have to sign a
+// multi-signature envelope before it is sent.
+func WithMultiSignatureWindow(window time.Duration) ServerOption {
+	return func(o *serverOptions) error {
+		o.verifierOpts = append(o.verifierOpts, auth.WithMultiSignatureWindow(window))
+		return nil
+	}
+}
+
+// WithAuthorizer checks every authenticated call against authorizer, such as
+// an *authz.Policy, failing with codes.PermissionDenied when it is not allowed.
+func WithAuthorizer(authorizer auth.Authorizer) ServerOption {
+	return func(o *serverOptions) error {
+		if authorizer == nil {
+			return fmt.Errorf("authorizer must not be nil")
+		}
+		o.verifierOpts = append(o.verifierOpts, auth.WithAuthorizer(authorizer))
+		return nil
+	}
+}
+
 func NewServerWithTrustedKeys(trustedKeys keystore.KeyStore) *Server {
-	return &Server{trustedKeys: trustedKeys}
+	// The default options are always valid.
+	verifier, _ := auth.NewVerifier(trustedKeys)
+	return &Server{
+		trustedKeys:          trustedKeys,
+		verifier:             verifier,
+		enrollmentChallenges: challenge.NewStore(DefaultChallengeTTL, maxOutstandingChallenges),
+		loginChallenges:      challenge.NewStore(DefaultChallengeTTL, maxOutstandingChallenges),
+		rotationChallenges:   challenge.NewStore(DefaultChallengeTTL, maxOutstandingChallenges),
+		network:              "tcp",
+		address:              DefaultAddress,
+		drainTimeout:         DefaultDrainTimeout,
+		ready:                make(chan struct{}),
+	}
 }
 
 func NewServerWithTrustedKeysAndFuncOpts(trustedKeys keystore.KeyStore, opts ...ServerOption) (*Server, error) {
 	server := NewServerWithTrustedKeys(trustedKeys)
 
 	// apply defaults
-	o := &serverOptions{}
+	o := &serverOptions{
+		network:      "tcp",
+		address:      DefaultAddress,
+		drainTimeout: DefaultDrainTimeout,
+	}
 
 	// apply user options
 	for _, opt := range opts {
@@ -70,6 +420,25 @@ func NewServerWithTrustedKeysAndFuncOpts(trustedKeys keystore.KeyStore, opts ...
 		}
 	}
 
+	verifier, err := auth.NewVerifier(trustedKeys, o.verifierOpts...)
+	if err != nil {
+		return nil, err
+	}
+	server.verifier = verifier
+	server.adminKeys = o.adminKeys
+	server.enrollment = o.enrollment
+	server.enrollmentPolicy = o.enrollmentPolicy
+	server.sessions = o.sessions
+	server.rotation = o.rotation
+	server.rotationGrace = o.rotationGrace
+	server.responseSigner = o.responseSigner
+	server.tlsConfig = o.tlsConfig
+	server.tracingTLS = o.tracingTLS
+	server.network = o.network
+	server.address = o.address
+	server.socketMode = o.socketMode
+	server.drainTimeout = o.drainTimeout
+
 	if o.enableTracing {
 		if err := server.SetupOpenTelemetry(o.tracingTarget, "grpc-app-auth-server"); err != nil {
 			return nil, err
@@ -80,74 +449,271 @@ func NewServerWithTrustedKeysAndFuncOpts(trustedKeys keystore.KeyStore, opts ...
 }
 
 func (s *Server) Echo(ctx context.Context, in *pb.EchoRequest) (*pb.EchoReply, error) {
-	pubKey, err := s.trustedKeys.GetPublicKey(in.PublicKey)
-	if err != nil {
-		return nil, status.Errorf(codes.Unauthenticated, "public key is not trusted")
-	}
-
-	verified := ed25519.Verify(pubKey, []byte(in.Message), in.Signature)
-	if !verified {
-		return nil, status.Errorf(codes.Unauthenticated, "signature is not valid")
-	}
-
 	return &pb.EchoReply{Message: "Echo " + in.Message}, nil
 }
 
 func (s *Server) Add(ctx context.Context, in *pb.AddRequest) (*pb.AddReply, error) {
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		return nil, status.Errorf(codes.Unauthenticated, "missing authentication metadata")
+	return &pb.AddReply{Result: in.A + in.B}, nil
+}
+
+// IdentityKeyID returns the fingerprint of the key set with WithIdentity, or
+// "" if responses are not signed.
+func (s *Server) IdentityKeyID() string {
+	if s.responseSigner == nil {
+		return ""
 	}
+	return s.responseSigner.KeyID()
+}
 
-	pubKey, err := s.trustedKeys.GetPublicKey(md["key"][0])
-	if err != nil {
-		return nil, status.Errorf(codes.Unauthenticated, "public key is not trusted")
+// Run serves until ctx is done, then shuts down gracefully: it stops
+// accepting connections and gives in-flight RPCs the drain timeout to finish
+// before closing them. It returns nil once shut down because ctx was done.
+func (s *Server) Run(ctx context.Context) error {
+	errs := make(chan error, 1)
+	go func() { errs <- s.Serve() }()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
 	}
 
-	signatureBytes, err := base64.StdEncoding.DecodeString(md["signature"][0])
-	if err != nil {
-		return nil, status.Errorf(codes.Unauthenticated, "malformed signature")
+	log.Printf("[server] Shutting down, draining for up to %v", s.drainTimeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), s.drainTimeout)
+	defer cancel()
+	if err := s.Shutdown(drainCtx); err != nil {
+		log.Printf("[server] Drain timeout passed, closed remaining RPCs")
 	}
 
-	verified := ed25519.Verify(pubKey, []byte(utils.AddCanonicalization(in.A, in.B)), signatureBytes)
-	if !verified {
-		return nil, status.Errorf(codes.Unauthenticated, "signature is not valid")
+	if err := <-errs; err != nil && !errors.Is(err, ErrServerStopped) {
+		return err
 	}
+	return nil
+}
 
-	return &pb.AddReply{Result: in.A + in.B}, nil
+// Ready returns a channel that is closed once the server is listening.
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
 }
 
-func (s *Server) Serve() {
-	s.grpcServer = grpc.NewServer(
+// Addr returns the address the server listens on, or nil until it is ready.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addr
+}
+
+// Serve listens on the configured address and serves until Stop or Shutdown
+// is called.
+func (s *Server) Serve() error {
+	lis, err := s.listen()
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	return s.ServeListener(lis)
+}
+
+// ServeListener serves on lis until Stop or Shutdown is called, closing lis when it
+// returns. It ignores the address options, so callers can supply any
+// listener, e.g. one on port 0 or handed over by a supervisor.
+func (s *Server) ServeListener(lis net.Listener) error {
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		loggingUnaryServerInterceptor,
+		otelgrpc.UnaryServerInterceptor(),
+	}
+	if s.responseSigner != nil {
+		unaryInterceptors = append(unaryInterceptors, s.responseSigner.UnaryServerInterceptor())
+	}
+	unaryInterceptors = append(unaryInterceptors, s.verifier.UnaryServerInterceptor())
+
+	opts := []grpc.ServerOption{
 		grpc.UnaryInterceptor(
-			grpc_middleware.ChainUnaryServer(
-				loggingUnaryServerInterceptor,
-				otelgrpc.UnaryServerInterceptor(),
+			grpc_middleware.ChainUnaryServer(unaryInterceptors...),
+		),
+		grpc.StreamInterceptor(
+			grpc_middleware.ChainStreamServer(
+				otelgrpc.StreamServerInterceptor(),
+				s.verifier.StreamServerInterceptor(),
 			),
 		),
-	)
-	pb.RegisterEchoServer(s.grpcServer, s)
-	pb.RegisterAddServer(s.grpcServer, s)
+	}
+	if s.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
+	}
 
-	lis, err := net.Listen("tcp", ":50051")
+	s.mu.Lock()
+	if s.stopped || s.grpcServer != nil {
+		s.mu.Unlock()
+		lis.Close()
+		if s.stopped {
+			return ErrServerStopped
+		}
+		return fmt.Errorf("server is already serving")
+	}
+	grpcServer := grpc.NewServer(opts...)
+	s.grpcServer = grpcServer
+	s.addr = lis.Addr()
+	pb.RegisterEchoServer(grpcServer, s)
+	pb.RegisterAddServer(grpcServer, s)
+	if s.adminKeys != nil {
+		pb.RegisterKeyAdminServer(grpcServer, s)
+	}
+	if s.enrollment {
+		pb.RegisterEnrollmentServer(grpcServer, s)
+	}
+	if s.sessions != nil {
+		pb.RegisterSessionServer(grpcServer, s)
+	}
+	if s.rotation {
+		pb.RegisterKeyRotationServer(grpcServer, s)
+	}
+	close(s.ready)
+	s.mu.Unlock()
+
+	log.Printf("[server] Listening on %s", lis.Addr())
+	if err := grpcServer.Serve(lis); errors.Is(err, grpc.ErrServerStopped) {
+		// Stop was called between releasing the lock and serving.
+		return ErrServerStopped
+	} else if err != nil {
+		return fmt.Errorf("failed to serve: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) listen() (net.Listener, error) {
+	if s.network != "unix" {
+		return net.Listen(s.network, s.address)
+	}
+
+	// A socket left by a server that did not shut down cleanly is replaced.
+	// Anything else at path, including a socket a server still accepts
+	// connections on, is left alone.
+	if info, err := os.Lstat(s.address); err == nil {
+		if info.Mode().Type() != os.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", s.address)
+		}
+		if conn, err := net.Dial("unix", s.address); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another server", s.address)
+		}
+	}
+
+	// The socket is created in a directory only the server can enter and
+	// renamed into place once its permissions are set, so no one can connect
+	// while it still has the umask's permissions.
+	dir, err := os.MkdirTemp(filepath.Dir(s.address), "."+filepath.Base(s.address)+".tmp")
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		return nil, err
 	}
+	defer os.RemoveAll(dir)
 
-	if err := s.grpcServer.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+	tmp := filepath.Join(dir, "socket")
+	if len(tmp) >= maxSocketPath {
+		return nil, fmt.Errorf("socket path %s is too long, the temporary socket %s must be shorter than %d bytes", s.address, tmp, maxSocketPath)
+	}
+	lis, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// Close would remove tmp, not the socket at its final path.
+	lis.SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmp, s.socketMode); err == nil {
+		err = os.Rename(tmp, s.address)
+	}
+	if err != nil {
+		lis.Close()
+		return nil, err
 	}
+	return &unixListener{UnixListener: lis, path: s.address}, nil
 }
 
+// maxSocketPath is the size of sun_path on macOS and the BSDs, the smallest of
+// the common platforms, and includes the terminating NUL.
+const maxSocketPath = 104
+
+// unixListener removes its socket when closed, like a net.UnixListener
+// created at its final path.
+type unixListener struct {
+	*net.UnixListener
+	path string
+	once sync.Once
+}
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	l.once.Do(func() { os.Remove(l.path) })
+	return err
+}
+
+// Stop closes all connections and in-flight RPCs immediately. It is safe to
+// call before, during or after Serve, and more than once; Serve returns
+// ErrServerStopped if it is called afterwards.
 func (s *Server) Stop() {
-	if s.tracerProvider != nil {
+	if grpcServer := s.markStopped(); grpcServer != nil {
+		grpcServer.Stop()
+	}
+	s.shutdownTracing()
+}
+
+// Shutdown stops accepting connections and waits for in-flight RPCs to
+// finish. If ctx is done first the remaining RPCs are closed and ctx's error
+// is returned. Like Stop, it is safe to call at any time.
+func (s *Server) Shutdown(ctx context.Context) error {
+	defer s.shutdownTracing()
+
+	grpcServer := s.markStopped()
+	if grpcServer == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		grpcServer.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// markStopped prevents Serve from starting and returns the running gRPC
+// server, if any.
+func (s *Server) markStopped() *grpc.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	return s.grpcServer
+}
+
+func (s *Server) shutdownTracing() {
+	s.tracingShutdown.Do(func() {
+		if s.tracerProvider == nil {
+			return
+		}
 		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 		defer cancel()
 		if err := s.tracerProvider.Shutdown(ctx); err != nil {
 			log.Printf("Failed to shut down TracerProvider: %v", err)
 		}
-	}
-	s.grpcServer.Stop()
+	})
+}
+
+// redactedMetadataKeys are never logged, since anyone reading the log could
+// reuse their values.
+var redactedMetadataKeys = []string{auth.AuthorizationMetadataKey, auth.SignatureMetadataKey, multisig.MetadataKey}
+
+// unloggedServices carry credentials in their request or response bodies, so
+// the bodies are never logged.
+var unloggedServices = map[string]bool{
+	"services.Enrollment": true,
+	"services.Session":    true,
 }
 
 func loggingUnaryServerInterceptor(
@@ -158,24 +724,45 @@ func loggingUnaryServerInterceptor(
 	spanContext := span.SpanContext()
 
 	md, _ := metadata.FromIncomingContext(ctx)
-	log.Printf("[server] Metadata: %v", md)
+	log.Printf("[server] Metadata: %v", redactMetadata(md))
 	log.Printf("[server] Trace ID: %s", spanContext.TraceID().String())
 	log.Printf("[server] Span ID: %s", spanContext.SpanID().String())
+
+	service, _, _ := canonical.SplitMethod(info.FullMethod)
+	if unloggedServices[service] {
+		log.Printf("[server] Request: %s (body not logged)", info.FullMethod)
+		return handler(ctx, req)
+	}
+
 	log.Printf("[server] Request: %+v", req)
 	resp, err := handler(ctx, req)
 	log.Printf("[server] Response: %+v", resp)
 	return resp, err
 }
 
+func redactMetadata(md metadata.MD) metadata.MD {
+	redacted := md.Copy()
+	for _, key := range redactedMetadataKeys {
+		if len(redacted.Get(key)) > 0 {
+			redacted.Set(key, "[REDACTED]")
+		}
+	}
+	return redacted
+}
+
 func (s *Server) SetupOpenTelemetry(target string, serviceName string) error {
 	ctx := context.Background()
 	ctx, cancel := context.WithTimeout(ctx, time.Second)
 
 	// Enough to shutdown the underlying connection since DialContext is used in blocking mode
 	defer cancel()
+	// Plaintext unless WithOpenTelemetryTLS was given. TLS is recommended in production.
+	creds := insecure.NewCredentials()
+	if s.tracingTLS != nil {
+		creds = credentials.NewTLS(s.tracingTLS)
+	}
 	conn, err := grpc.DialContext(ctx, target,
-		// Note the use of insecure transport here. TLS is recommended in production.
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(creds),
 		grpc.WithBlock(),
 	)
 	if err != nil {
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"grpc-app-auth/auth"
+	"grpc-app-auth/canonical"
+	pb "grpc-app-auth/services"
+	"grpc-app-auth/session"
+	"grpc-app-auth/signing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const DefaultSessionTTL = 15 * time.Minute
+
+// GetLoginChallenge issues a single-use challenge for Login.
+func (s *Server) GetLoginChallenge(ctx context.Context, in *pb.ChallengeRequest) (*pb.ChallengeReply, error) {
+	return issueChallenge(s.loginChallenges)
+}
+
+// Login exchanges a challenge signed by a trusted key for a session token.
+func (s *Server) Login(ctx context.Context, in *pb.LoginRequest) (*pb.LoginReply, error) {
+	if !s.loginChallenges.Consume(in.Challenge) {
+		return nil, status.Errorf(codes.Unauthenticated, "challenge is unknown or expired")
+	}
+
+	record, err := s.trustedKeys.GetKeyRecord(in.KeyId)
+	if err == nil {
+		err = record.Validate(time.Now())
+	}
+	if err != nil {
+		return nil, auth.KeyError(err)
+	}
+
+	if !s.verifier.AllowsAlgorithm(record.Algorithm) {
+		return nil, status.Errorf(codes.Unauthenticated, "key algorithm is not allowed")
+	}
+
+	if signing.Verify(record.Algorithm, record.PublicKey, canonical.EncodeLogin(in.Challenge, in.KeyId), in.Signature) != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "signature is not valid")
+	}
+
+	token, claims, err := s.sessions.Issue(session.Claims{
+		KeyID:  in.KeyId,
+		Label:  record.Label,
+		Groups: record.Groups,
+		Scopes: in.Scopes,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not issue session token")
+	}
+
+	return &pb.LoginReply{Token: token, ExpiresAt: claims.ExpiresAt}, nil
+}
@@ -0,0 +1,120 @@
+// Package session issues and verifies short-lived session tokens.
+//
+// A token is the base64url encoded JSON claims and the base64url encoded
+// ed25519 signature over their canonical session encoding, joined by a '.'.
+// Verifying a token only needs the issuer's public key.
+package session
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"grpc-app-auth/canonical"
+)
+
+// AllMethods is the scope granting access to every method.
+const AllMethods = "*"
+
+var (
+	ErrMalformedToken = errors.New("malformed session token")
+	ErrInvalidToken   = errors.New("session token signature is not valid")
+	ErrExpiredToken   = errors.New("session token has expired")
+)
+
+// Claims are the contents of a session token.
+type Claims struct {
+	KeyID string `json:"kid"`
+	// Label and Groups are copied from the key record at login so that
+	// authorization policies can be evaluated without a KeyStore lookup.
+	Label  string   `json:"label,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+	// Scopes are full method names the token may call. A scope ending in '*'
+	// matches every method with that prefix, e.g. "/services.Echo/*".
+	Scopes    []string `json:"scopes"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+// Allows reports whether the claims grant access to fullMethod.
+func (c *Claims) Allows(fullMethod string) bool {
+	for _, scope := range c.Scopes {
+		if canonical.MatchMethod(scope, fullMethod) {
+			return true
+		}
+	}
+	return false
+}
+
+// Issuer signs session tokens.
+type Issuer struct {
+	privateKey ed25519.PrivateKey
+	ttl        time.Duration
+}
+
+func NewIssuer(privateKey ed25519.PrivateKey, ttl time.Duration) *Issuer {
+	return &Issuer{privateKey: privateKey, ttl: ttl}
+}
+
+// PublicKey returns the key that verifies the issuer's tokens.
+func (i *Issuer) PublicKey() ed25519.PublicKey {
+	return i.privateKey.Public().(ed25519.PublicKey)
+}
+
+// Issue returns a token for the given claims, filling in the issue and expiry
+// times. No scopes grants AllMethods.
+func (i *Issuer) Issue(claims Claims) (string, *Claims, error) {
+	if len(claims.Scopes) == 0 {
+		claims.Scopes = []string{AllMethods}
+	}
+
+	now := time.Now()
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(i.ttl).Unix()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	signature := ed25519.Sign(i.privateKey, canonical.EncodeSession(payload))
+	token := base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return token, &claims, nil
+}
+
+// Verify checks the token's signature and expiry and returns its claims.
+func Verify(publicKey ed25519.PublicKey, token string, now time.Time) (*Claims, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrMalformedToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	if !ed25519.Verify(publicKey, canonical.EncodeSession(payload), signature) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+
+	if !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrExpiredToken
+	}
+
+	return &claims, nil
+}
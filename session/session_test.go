@@ -0,0 +1,66 @@
+package session
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssueAndVerify(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := NewIssuer(privateKey, time.Minute)
+
+	token, _, err := issuer.Issue(Claims{KeyID: "key", Scopes: []string{"/services.Echo/*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := Verify(issuer.PublicKey(), token, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.KeyID != "key" {
+		t.Fatalf("unexpected key ID %q", claims.KeyID)
+	}
+	if !claims.Allows("/services.Echo/Echo") || claims.Allows("/services.Add/Add") {
+		t.Fatalf("unexpected scope evaluation for %v", claims.Scopes)
+	}
+
+	if _, err := Verify(issuer.PublicKey(), token, time.Now().Add(2*time.Minute)); !errors.Is(err, ErrExpiredToken) {
+		t.Fatalf("expected expired token, got %v", err)
+	}
+
+	payload, signature, _ := strings.Cut(token, ".")
+	forged := payload[:len(payload)-2] + "AA." + signature
+	if _, err := Verify(issuer.PublicKey(), forged, time.Now()); err == nil {
+		t.Fatal("expected forged token to be rejected")
+	}
+
+	otherPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(otherPublicKey, token, time.Now()); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected invalid token, got %v", err)
+	}
+}
+
+func TestNoScopesGrantsAllMethods(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, claims, err := NewIssuer(privateKey, time.Minute).Issue(Claims{KeyID: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !claims.Allows("/services.Add/Add") {
+		t.Fatal("expected token without scopes to allow every method")
+	}
+}
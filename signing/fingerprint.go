@@ -0,0 +1,55 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// multibaseBase64URL is the multibase prefix for unpadded base64url.
+const multibaseBase64URL = "u"
+
+// Fingerprint returns the key ID of a public key stored for alg: the
+// algorithm, a colon and the SHA-256 of the stored key in multibase
+// base64url, e.g. "ed25519:u1NXxq...". The empty algorithm is Ed25519.
+func Fingerprint(alg Algorithm, publicKey []byte) string {
+	if alg == "" {
+		alg = Ed25519
+	}
+	sum := sha256.Sum256(publicKey)
+	return string(alg) + ":" + multibaseBase64URL + base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// PublicKeyFingerprint returns the fingerprint of publicKey.
+func PublicKeyFingerprint(publicKey crypto.PublicKey) (string, error) {
+	data, alg, err := MarshalPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	return Fingerprint(alg, data), nil
+}
+
+// ParseFingerprint splits a fingerprint into its algorithm and SHA-256.
+func ParseFingerprint(fingerprint string) (Algorithm, []byte, error) {
+	name, encoded, ok := strings.Cut(fingerprint, ":")
+	if !ok || name == "" {
+		return "", nil, fmt.Errorf("fingerprint %q has no algorithm", fingerprint)
+	}
+
+	alg, err := ParseAlgorithm(name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	encoded, ok = strings.CutPrefix(encoded, multibaseBase64URL)
+	if !ok {
+		return "", nil, fmt.Errorf("fingerprint %q is not multibase base64url", fingerprint)
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(sum) != sha256.Size {
+		return "", nil, fmt.Errorf("fingerprint %q is not a SHA-256", fingerprint)
+	}
+	return alg, sum, nil
+}
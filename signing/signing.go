@@ -0,0 +1,192 @@
+// Package signing implements the signature algorithms a key may use.
+//
+// Ed25519 public keys are stored as their raw 32 bytes. Every other
+// algorithm stores its public key as a PKIX (SubjectPublicKeyInfo) DER
+// encoding. ECDSA signatures are ASN.1 DER encoded, as produced by
+// crypto.Signer, and RSA-PSS signatures use a salt as long as the hash.
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Algorithm names a signature scheme together with its hash.
+type Algorithm string
+
+const (
+	Ed25519         Algorithm = "ed25519"
+	ECDSAP256SHA256 Algorithm = "ecdsa-p256-sha256"
+	RSAPSSSHA256    Algorithm = "rsa-pss-sha256"
+)
+
+// MinRSAKeySize is the smallest RSA modulus, in bits, that is accepted.
+const MinRSAKeySize = 2048
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signature algorithm")
+	ErrInvalidSignature     = errors.New("signature is not valid")
+)
+
+// Algorithms returns every supported algorithm.
+func Algorithms() []Algorithm {
+	return []Algorithm{Ed25519, ECDSAP256SHA256, RSAPSSSHA256}
+}
+
+// ParseAlgorithm returns the named algorithm. The empty name is Ed25519, so
+// records written before algorithms were recorded keep working.
+func ParseAlgorithm(name string) (Algorithm, error) {
+	if name == "" {
+		return Ed25519, nil
+	}
+	for _, alg := range Algorithms() {
+		if string(alg) == name {
+			return alg, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, name)
+}
+
+// AlgorithmOf returns the algorithm used to sign with keys of the same type
+// as publicKey.
+func AlgorithmOf(publicKey crypto.PublicKey) (Algorithm, error) {
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		return Ed25519, nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return "", fmt.Errorf("%w: ECDSA curve %s", ErrUnsupportedAlgorithm, key.Curve.Params().Name)
+		}
+		return ECDSAP256SHA256, nil
+	case *rsa.PublicKey:
+		if key.N.BitLen() < MinRSAKeySize {
+			return "", fmt.Errorf("RSA key must be at least %d bits", MinRSAKeySize)
+		}
+		return RSAPSSSHA256, nil
+	}
+	return "", fmt.Errorf("%w: %T", ErrUnsupportedAlgorithm, publicKey)
+}
+
+// MarshalPublicKey returns the stored form of publicKey and its algorithm.
+func MarshalPublicKey(publicKey crypto.PublicKey) ([]byte, Algorithm, error) {
+	alg, err := AlgorithmOf(publicKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if alg == Ed25519 {
+		return append([]byte(nil), publicKey.(ed25519.PublicKey)...), alg, nil
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return der, alg, nil
+}
+
+// ParsePublicKey decodes a public key stored for alg, checking that it
+// really is a key for that algorithm. The empty algorithm is Ed25519.
+func ParsePublicKey(alg Algorithm, data []byte) (crypto.PublicKey, error) {
+	alg, err := ParseAlgorithm(string(alg))
+	if err != nil {
+		return nil, err
+	}
+
+	if alg == Ed25519 {
+		if len(data) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("ed25519 key must be %d bytes", ed25519.PublicKeySize)
+		}
+		return ed25519.PublicKey(data), nil
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("malformed %s key: %w", alg, err)
+	}
+
+	actual, err := AlgorithmOf(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if actual != alg {
+		return nil, fmt.Errorf("key is %s, not %s", actual, alg)
+	}
+	return publicKey, nil
+}
+
+// MessageSigner is a crypto.Signer that hashes the message itself, such as a
+// signing agent that only accepts whole messages. Sign prefers SignMessage
+// when a signer implements it.
+type MessageSigner interface {
+	crypto.Signer
+	SignMessage(rand io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+// Sign signs message with signer using the algorithm matching its public key.
+func Sign(signer crypto.Signer, message []byte) ([]byte, Algorithm, error) {
+	alg, err := AlgorithmOf(signer.Public())
+	if err != nil {
+		return nil, "", err
+	}
+
+	var opts crypto.SignerOpts
+	switch alg {
+	case Ed25519:
+		opts = crypto.Hash(0)
+	case ECDSAP256SHA256:
+		opts = crypto.SHA256
+	case RSAPSSSHA256:
+		opts = pssOptions
+	}
+
+	var signature []byte
+	if ms, ok := signer.(MessageSigner); ok {
+		signature, err = ms.SignMessage(rand.Reader, message, opts)
+	} else if opts.HashFunc() == 0 {
+		signature, err = signer.Sign(rand.Reader, message, opts)
+	} else {
+		digest := sha256.Sum256(message)
+		signature, err = signer.Sign(rand.Reader, digest[:], opts)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return signature, alg, nil
+}
+
+// Verify checks signature over message against a public key stored for alg.
+// The empty algorithm is Ed25519.
+func Verify(alg Algorithm, publicKey []byte, message []byte, signature []byte) error {
+	key, err := ParsePublicKey(alg, publicKey)
+	if err != nil {
+		return err
+	}
+
+	var ok bool
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, message, signature)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		ok = ecdsa.VerifyASN1(key, digest[:], signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		ok = rsa.VerifyPSS(key, crypto.SHA256, digest[:], signature, pssOptions) == nil
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+var pssOptions = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
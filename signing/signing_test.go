@@ -0,0 +1,142 @@
+package signing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func generateKeys(t *testing.T) map[Algorithm]crypto.Signer {
+	t.Helper()
+
+	_, edKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, MinRSAKeySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[Algorithm]crypto.Signer{
+		Ed25519:         edKey,
+		ECDSAP256SHA256: ecKey,
+		RSAPSSSHA256:    rsaKey,
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	message := []byte("message")
+
+	for want, key := range generateKeys(t) {
+		publicKey, alg, err := MarshalPublicKey(key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if alg != want {
+			t.Fatalf("expected %s, got %s", want, alg)
+		}
+
+		signature, alg, err := Sign(key, message)
+		if err != nil {
+			t.Fatalf("%s: %v", want, err)
+		}
+		if alg != want {
+			t.Fatalf("expected %s signature, got %s", want, alg)
+		}
+
+		if err := Verify(alg, publicKey, message, signature); err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if err := Verify(alg, publicKey, []byte("other message"), signature); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("%s: expected invalid signature, got %v", alg, err)
+		}
+	}
+}
+
+func TestParsePublicKeyChecksAlgorithm(t *testing.T) {
+	keys := generateKeys(t)
+
+	ecPublicKey, _, err := MarshalPublicKey(keys[ECDSAP256SHA256].Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParsePublicKey(RSAPSSSHA256, ecPublicKey); err == nil {
+		t.Fatal("expected an ECDSA key to be rejected as RSA")
+	}
+	if _, err := ParsePublicKey(Ed25519, ecPublicKey); err == nil {
+		t.Fatal("expected an ECDSA key to be rejected as ed25519")
+	}
+
+	edPublicKey, _, err := MarshalPublicKey(keys[Ed25519].Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParsePublicKey("", edPublicKey); err != nil {
+		t.Fatalf("expected the empty algorithm to mean ed25519: %v", err)
+	}
+	if _, err := ParsePublicKey("dsa", edPublicKey); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Fatalf("expected unsupported algorithm, got %v", err)
+	}
+}
+
+func TestWeakKeysAreRejected(t *testing.T) {
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AlgorithmOf(p384Key.Public()); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Fatalf("expected P-384 to be unsupported, got %v", err)
+	}
+
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AlgorithmOf(smallKey.Public()); err == nil {
+		t.Fatal("expected a 1024 bit RSA key to be rejected")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	// The ed25519 public key from RFC 8032 test 1.
+	publicKey, err := hex.DecodeString("d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fingerprint := Fingerprint(Ed25519, publicKey)
+	if fingerprint != Fingerprint("", publicKey) {
+		t.Fatal("expected the empty algorithm to fingerprint as ed25519")
+	}
+	if !strings.HasPrefix(fingerprint, "ed25519:u") || len(fingerprint) != len("ed25519:u")+43 {
+		t.Fatalf("unexpected fingerprint %q", fingerprint)
+	}
+
+	alg, sum, err := ParseFingerprint(fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sha256.Sum256(publicKey); alg != Ed25519 || !bytes.Equal(sum, want[:]) {
+		t.Fatalf("unexpected parse %s %x", alg, sum)
+	}
+
+	for _, invalid := range []string{"", "u" + fingerprint[9:], "ed25519:" + fingerprint[9:], "dsa:" + fingerprint[8:], "ed25519:uAAAA"} {
+		if _, _, err := ParseFingerprint(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package tlsutil
+
+import (
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelfSignedCertificate(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := SelfSignedCertificate(privateKey, "localhost", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leaf.DNSNames) != 1 || len(leaf.IPAddresses) != 1 || !leaf.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("unexpected names %v %v", leaf.DNSNames, leaf.IPAddresses)
+	}
+
+	if err := VerifyPinnedKey(publicKey)(cert.Certificate, nil); err != nil {
+		t.Fatalf("pinned key was rejected: %v", err)
+	}
+
+	otherPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyPinnedKey(otherPublicKey)(cert.Certificate, nil); !errors.Is(err, ErrUnpinnedKey) {
+		t.Fatalf("expected unpinned key, got %v", err)
+	}
+	if _, err := PinnedClientConfig(nil); err == nil {
+		t.Fatal("expected a config without pinned keys to be rejected")
+	}
+}
+
+func TestConfigFromPEMFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir, "server")
+	caFile, _ := writeKeyPair(t, dir, "client")
+
+	server, err := ServerConfig(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.ClientAuth != tls.RequireAndVerifyClientCert || server.MinVersion != tls.VersionTLS13 {
+		t.Fatalf("unexpected server config %+v", server)
+	}
+
+	client, err := ClientConfig(certFile, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.RootCAs == nil || len(client.Certificates) != 0 {
+		t.Fatalf("unexpected client config %+v", client)
+	}
+
+	if _, err := LoadCertPool(keyFile); err == nil {
+		t.Fatal("expected a file without certificates to be rejected")
+	}
+}
+
+// writeKeyPair writes a self-signed certificate for localhost and its key as
+// PEM files in dir.
+func writeKeyPair(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := SelfSignedCertificate(privateKey, "localhost", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}